@@ -2,6 +2,8 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -12,20 +14,59 @@ import (
 
 type Controller struct {
 	service *service.Service
+	tokens  Tokens
+	policy  Policy
+	logger  *slog.Logger
 }
 
-func NewController(service *service.Service) *Controller {
+func NewController(service *service.Service, tokens Tokens, policy Policy, logger *slog.Logger) *Controller {
 	return &Controller{
 		service: service,
+		tokens:  tokens,
+		policy:  policy,
+		logger:  logger,
 	}
 }
 
 func (c *Controller) GetAllTeachersHandler(w http.ResponseWriter, r *http.Request) {
-	data := c.service.GetAllTeachers()
+	if !c.authorize(w, r, ActionListTeachers, Resource{}) {
+		return
+	}
+	params, err := utils.ParseListParams(r.URL.Query())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data, err := c.service.GetAllTeachers(params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, data)
+}
+
+// GetTeacherHandler возвращает преподавателя по ID из параметра запроса ?id=
+func (c *Controller) GetTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Неверный ID")
+		return
+	}
+	if !c.authorize(w, r, ActionReadTeacher, Resource{}) {
+		return
+	}
+	data, err := c.service.GetTeacherByID(id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusOK, data)
 }
 
 func (c *Controller) CreateTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.authorize(w, r, ActionCreateTeacher, Resource{}) {
+		return
+	}
 	var teacher models.Teacher
 	err := json.NewDecoder(r.Body).Decode(&teacher)
 	if err != nil {
@@ -34,11 +75,17 @@ func (c *Controller) CreateTeacherHandler(w http.ResponseWriter, r *http.Request
 	}
 	defer r.Body.Close()
 
-	c.service.CreateTeacher(teacher)
+	if err := c.service.CreateTeacher(teacher); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "Преподаватель успешно создан"})
 }
 
 func (c *Controller) UpdateTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.authorize(w, r, ActionUpdateTeacher, Resource{}) {
+		return
+	}
 	var teacher models.Teacher
 	err := json.NewDecoder(r.Body).Decode(&teacher)
 	if err != nil {
@@ -49,14 +96,36 @@ func (c *Controller) UpdateTeacherHandler(w http.ResponseWriter, r *http.Request
 
 	err = c.service.UpdateTeacher(teacher)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		respondWithServiceError(w, err)
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Преподаватель успешно обновлен"})
 }
 
+// PatchTeacherHandler частично обновляет преподавателя: PATCH /teachers/update
+// с JSON Merge Patch в теле, запись выбирается по полю id
+func (c *Controller) PatchTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.authorize(w, r, ActionUpdateTeacher, Resource{}) {
+		return
+	}
+	id, patch, ok := decodePatch(w, r)
+	if !ok {
+		return
+	}
+
+	teacher, err := c.service.PatchTeacher(id, patch)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, teacher)
+}
+
 func (c *Controller) DeleteTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.authorize(w, r, ActionDeleteTeacher, Resource{}) {
+		return
+	}
 	var req struct {
 		ID int `json:"id"`
 	}
@@ -67,17 +136,38 @@ func (c *Controller) DeleteTeacherHandler(w http.ResponseWriter, r *http.Request
 	}
 	defer r.Body.Close()
 
-	c.service.DeleteTeacher(req.ID)
+	if err := c.service.DeleteTeacher(req.ID); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Преподаватель успешно удален"})
 }
 
 func (c *Controller) GetAllCoursesHandler(w http.ResponseWriter, r *http.Request) {
-	data := c.service.GetAllCourses()
+	if !c.authorize(w, r, ActionListCourses, Resource{}) {
+		return
+	}
+	params, err := utils.ParseListParams(r.URL.Query())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data, err := c.service.GetAllCourses(params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusOK, data)
 }
 
+// UpdateCourseHandler заменяет курс. Владелец курса известен только после чтения тела,
+// поэтому до разбора проверяется токен, а политика — после
 func (c *Controller) UpdateCourseHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := c.authenticate(w, r)
+	if !ok {
+		return
+	}
 	var course models.Course
 	err := json.NewDecoder(r.Body).Decode(&course)
 	if err != nil {
@@ -85,17 +175,81 @@ func (c *Controller) UpdateCourseHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	defer r.Body.Close()
+	// Преподаватель не может передать свой курс другому преподавателю
+	if c.allow(principal, ActionUpdateCourse, Resource{TeacherID: course.TeacherID}) != nil {
+		respondForbidden(w)
+		return
+	}
 
-	err = c.service.UpdateCourse(course)
+	// Текущий владелец проверяется под блокировкой таблицы: курс могли передать
+	// другому преподавателю после того, как клиент его прочитал
+	err = c.service.UpdateCourse(course, func(current, updated models.Course) error {
+		return c.allow(principal, ActionUpdateCourse, Resource{TeacherID: current.TeacherID})
+	})
+	if errors.Is(err, ErrForbidden) {
+		respondForbidden(w)
+		return
+	}
 	if err != nil {
-		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		respondWithServiceError(w, err)
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Курс успешно обновлен"})
 }
 
+// PatchCourseHandler частично обновляет курс: PATCH /courses/update
+func (c *Controller) PatchCourseHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := c.authenticate(w, r)
+	if !ok {
+		return
+	}
+	id, patch, ok := decodePatch(w, r)
+	if !ok {
+		return
+	}
+
+	// Проверка выполняется под блокировкой таблицы, поэтому ответ отправляется после PatchCourse
+	course, err := c.service.PatchCourse(id, patch, func(current, patched models.Course) error {
+		// Преподаватель не может передать свой курс другому преподавателю
+		if err := c.allow(principal, ActionUpdateCourse, Resource{TeacherID: current.TeacherID}); err != nil {
+			return err
+		}
+		return c.allow(principal, ActionUpdateCourse, Resource{TeacherID: patched.TeacherID})
+	})
+	if errors.Is(err, ErrForbidden) {
+		respondForbidden(w)
+		return
+	}
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, course)
+}
+
+// GetCourseHandler возвращает курса по ID из параметра запроса ?id=
+func (c *Controller) GetCourseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Неверный ID")
+		return
+	}
+	if !c.authorize(w, r, ActionReadCourse, Resource{}) {
+		return
+	}
+	data, err := c.service.GetCourseByID(id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, data)
+}
+
 func (c *Controller) CreateCourseHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.authorize(w, r, ActionCreateCourse, Resource{}) {
+		return
+	}
 	var course models.Course
 	err := json.NewDecoder(r.Body).Decode(&course)
 	if err != nil {
@@ -104,11 +258,17 @@ func (c *Controller) CreateCourseHandler(w http.ResponseWriter, r *http.Request)
 	}
 	defer r.Body.Close()
 
-	c.service.CreateCourse(course)
+	if err := c.service.CreateCourse(course); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "Курс успешно создан"})
 }
 
 func (c *Controller) DeleteCourseHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.authorize(w, r, ActionDeleteCourse, Resource{}) {
+		return
+	}
 	var req struct {
 		ID int `json:"id"`
 	}
@@ -119,17 +279,53 @@ func (c *Controller) DeleteCourseHandler(w http.ResponseWriter, r *http.Request)
 	}
 	defer r.Body.Close()
 
-	c.service.DeleteCourse(req.ID)
+	if err := c.service.DeleteCourse(req.ID); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Курс успешно удален"})
 }
 
 func (c *Controller) GetAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
-	data := c.service.GetAllStudents()
+	if !c.authorize(w, r, ActionListStudents, Resource{}) {
+		return
+	}
+	params, err := utils.ParseListParams(r.URL.Query())
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	data, err := c.service.GetAllStudents(params)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, data)
+}
+
+// GetStudentHandler возвращает студента по ID из параметра запроса ?id=
+func (c *Controller) GetStudentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Неверный ID")
+		return
+	}
+	if !c.authorize(w, r, ActionReadStudent, Resource{StudentID: id}) {
+		return
+	}
+	data, err := c.service.GetStudentByID(id)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusOK, data)
 }
 
 func (c *Controller) CreateStudentHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.authorize(w, r, ActionCreateStudent, Resource{}) {
+		return
+	}
 	var student models.Student
 	err := json.NewDecoder(r.Body).Decode(&student)
 	if err != nil {
@@ -138,11 +334,20 @@ func (c *Controller) CreateStudentHandler(w http.ResponseWriter, r *http.Request
 	}
 	defer r.Body.Close()
 
-	c.service.CreateStudent(student)
+	if err := c.service.CreateStudent(student); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusCreated, map[string]string{"message": "Студент успешно создан"})
 }
 
+// UpdateStudentHandler заменяет студента. ID записи передается в теле,
+// поэтому до разбора проверяется токен, а политика — после
 func (c *Controller) UpdateStudentHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := c.authenticate(w, r)
+	if !ok {
+		return
+	}
 	var student models.Student
 	err := json.NewDecoder(r.Body).Decode(&student)
 	if err != nil {
@@ -150,11 +355,41 @@ func (c *Controller) UpdateStudentHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 	defer r.Body.Close()
+	if c.allow(principal, ActionUpdateStudent, Resource{StudentID: student.ID}) != nil {
+		respondForbidden(w)
+		return
+	}
 
-	c.service.UpdateStudent(student)
+	if err := c.service.UpdateStudent(student); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Студент успешно обновлен"})
 }
 
+// PatchStudentHandler частично обновляет студента: PATCH /students/update
+func (c *Controller) PatchStudentHandler(w http.ResponseWriter, r *http.Request) {
+	principal, ok := c.authenticate(w, r)
+	if !ok {
+		return
+	}
+	id, patch, ok := decodePatch(w, r)
+	if !ok {
+		return
+	}
+	if c.allow(principal, ActionUpdateStudent, Resource{StudentID: id}) != nil {
+		respondForbidden(w)
+		return
+	}
+
+	student, err := c.service.PatchStudent(id, patch)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, student)
+}
+
 func (c *Controller) DeleteStudentHandler(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
 	id, err := strconv.Atoi(idStr)
@@ -162,6 +397,44 @@ func (c *Controller) DeleteStudentHandler(w http.ResponseWriter, r *http.Request
 		utils.RespondWithError(w, http.StatusBadRequest, "Неверный ID")
 		return
 	}
-	c.service.DeleteStudent(id)
+	if !c.authorize(w, r, ActionDeleteStudent, Resource{StudentID: id}) {
+		return
+	}
+	if err := c.service.DeleteStudent(id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Студент успешно удален"})
 }
+
+// decodePatch читает JSON Merge Patch из тела запроса; ID записи берется из поля id.
+// При ошибке отвечает 400 и возвращает false
+func decodePatch(w http.ResponseWriter, r *http.Request) (int, service.MergePatch, bool) {
+	defer r.Body.Close()
+	var patch service.MergePatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch == nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Неверный формат JSON")
+		return 0, nil, false
+	}
+	id, ok := patch["id"].(float64)
+	if !ok || id != float64(int(id)) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Неверный ID")
+		return 0, nil, false
+	}
+	return int(id), patch, true
+}
+
+// respondWithServiceError отвечает 422, если результат изменения не прошел проверку,
+// 404, если записи нет, и 500 на остальные ошибки, в том числе сбой сохранения
+func respondWithServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalid):
+		utils.RespondWithError(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, service.ErrNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrStorage):
+		utils.RespondWithError(w, http.StatusInternalServerError, "Не удалось сохранить изменения")
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, "Внутренняя ошибка сервера")
+	}
+}
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Laba2/models"
+	"Laba2/service"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testTokens токены администратора, двух преподавателей и студента; ID в хранилище начинаются с 0
+var testTokens = Tokens{
+	"admin":    {Name: "admin#1", Role: RoleAdmin},
+	"teacher0": {Name: "teacher#2", Role: RoleTeacher, EntityID: 0},
+	"teacher1": {Name: "teacher#3", Role: RoleTeacher, EntityID: 1},
+	"student0": {Name: "student#4", Role: RoleStudent, EntityID: 0},
+}
+
+// newTestController создает контроллер над хранилищем в памяти с преподавателями 0 и 1,
+// курсом 0 преподавателя 0 и студентом 0
+func newTestController(t *testing.T) *Controller {
+	t.Helper()
+	ds, err := service.NewDataSource(service.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ds.Close() })
+	svc := service.NewService(ds, testLogger)
+	for _, err := range []error{
+		svc.CreateTeacher(models.Teacher{Name: "Alex Kov", Email: "alex@example.com"}),
+		svc.CreateTeacher(models.Teacher{Name: "Ulia Ykubovskay", Email: "ulia@example.com"}),
+		svc.CreateCourse(models.Course{Title: "Go", TeacherID: 0, Price: 100}),
+		svc.CreateStudent(models.Student{Name: "Misha Fedotov", Email: "misha@example.com"}),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewController(svc, testTokens, RolePolicy{}, testLogger)
+}
+
+// serve вызывает handler с токеном token (пустой — без авторизации)
+func serve(handler http.HandlerFunc, method, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestPatchCourseHandler(t *testing.T) {
+	c := newTestController(t)
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"no token", "", `{"id":0,"title":"Go 2"}`, http.StatusUnauthorized},
+		{"own course", "teacher0", `{"id":0,"title":"Go 2"}`, http.StatusOK},
+		{"hand over to another teacher", "teacher0", `{"id":0,"teacher_id":1}`, http.StatusForbidden},
+		{"someone else's course", "teacher1", `{"id":0,"title":"Mine"}`, http.StatusForbidden},
+		{"invalid result", "admin", `{"id":0,"price":-1}`, http.StatusUnprocessableEntity},
+		{"missing course", "admin", `{"id":42,"title":"Go"}`, http.StatusNotFound},
+		// Отказ не оставляет таблицу заблокированной
+		{"after denial", "admin", `{"id":0,"teacher_id":1}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(c.PatchCourseHandler, http.MethodPatch, tt.token, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestRespondWithServiceError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"invalid", service.ErrInvalid, http.StatusUnprocessableEntity},
+		{"not found", service.ErrNotFound, http.StatusNotFound},
+		{"storage", service.ErrStorage, http.StatusInternalServerError},
+		{"unknown", io.ErrUnexpectedEOF, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			respondWithServiceError(w, tt.err)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestAccessIsCheckedBeforeBody(t *testing.T) {
+	c := newTestController(t)
+	handlers := map[string]http.HandlerFunc{
+		"update teacher": c.UpdateTeacherHandler,
+		"patch teacher":  c.PatchTeacherHandler,
+		"delete teacher": c.DeleteTeacherHandler,
+		"update course":  c.UpdateCourseHandler,
+		"patch course":   c.PatchCourseHandler,
+		"delete course":  c.DeleteCourseHandler,
+		"update student": c.UpdateStudentHandler,
+		"patch student":  c.PatchStudentHandler,
+	}
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			if w := serve(handler, http.MethodPut, "", "{"); w.Code != http.StatusUnauthorized {
+				t.Fatalf("without token: status = %d, want 401: %s", w.Code, w.Body)
+			}
+		})
+	}
+
+	// Для действий, не зависящих от записи, политика проверяется до разбора тела
+	for _, name := range []string{"update teacher", "patch teacher", "delete teacher", "delete course"} {
+		t.Run(name+" by student", func(t *testing.T) {
+			if w := serve(handlers[name], http.MethodPut, "student0", "{"); w.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want 403: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestUpdateCourseHandler(t *testing.T) {
+	c := newTestController(t)
+	tests := []struct {
+		name       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"own course", "teacher0", `{"id":0,"title":"Go 2","teacher_id":0,"price":100}`, http.StatusOK},
+		{"hand over to another teacher", "teacher0", `{"id":0,"title":"Go","teacher_id":1,"price":100}`, http.StatusForbidden},
+		// Курс, которого нет, и чужой курс неотличимы
+		{"foreign owner on missing course", "teacher0", `{"id":42,"title":"Go","teacher_id":1,"price":100}`, http.StatusForbidden},
+		{"reassigned by admin", "admin", `{"id":0,"title":"Go","teacher_id":1,"price":100}`, http.StatusOK},
+		// Владелец проверяется по записи в момент изменения, а не по тому, что видел клиент
+		{"former owner", "teacher0", `{"id":0,"title":"Mine","teacher_id":0,"price":100}`, http.StatusForbidden},
+		{"missing course", "admin", `{"id":42,"title":"Go","teacher_id":0,"price":100}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(c.UpdateCourseHandler, http.MethodPut, tt.token, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+	if course, err := c.service.GetCourseByID(0); err != nil || course.Title != "Go" || course.TeacherID != 1 {
+		t.Fatalf("course = %+v, %v", course, err)
+	}
+}
+
+func TestGetByIDHandlers(t *testing.T) {
+	c := newTestController(t)
+	handlers := map[string]http.HandlerFunc{
+		"teacher": c.GetTeacherHandler,
+		"course":  c.GetCourseHandler,
+		"student": c.GetStudentHandler,
+	}
+	tests := []struct {
+		query      string
+		wantStatus int
+	}{
+		{"?id=0", http.StatusOK},
+		{"?id=99", http.StatusNotFound},
+		{"?id=abc", http.StatusBadRequest},
+		{"", http.StatusBadRequest},
+	}
+	for kind, handler := range handlers {
+		for _, tt := range tests {
+			r := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			r.Header.Set("Authorization", "Bearer admin")
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("get %s %s: status = %d, want %d: %s", kind, tt.query, w.Code, tt.wantStatus, w.Body)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Laba2/utils"
+)
+
+// Роли пользователей
+const (
+	RoleAdmin   = "admin"
+	RoleTeacher = "teacher"
+	RoleStudent = "student"
+)
+
+// Principal пользователь, предъявивший токен
+type Principal struct {
+	Name string
+	Role string
+	// EntityID ID преподавателя или студента, с которым связан пользователь
+	EntityID int
+}
+
+// Tokens сопоставляет токен доступа пользователю
+type Tokens map[string]Principal
+
+// ParseTokens разбирает список токенов вида "token:role[:id],..."; id нужен ролям teacher и student
+func ParseTokens(value string) (Tokens, error) {
+	tokens := make(Tokens)
+	for i, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("token #%d: expected token:role[:id]", i+1)
+		}
+		principal := Principal{Name: fmt.Sprintf("%s#%d", parts[1], i+1), Role: parts[1]}
+		switch principal.Role {
+		case RoleAdmin:
+		case RoleTeacher, RoleStudent:
+			if len(parts) != 3 {
+				return nil, fmt.Errorf("token #%d: role %s requires an id", i+1, principal.Role)
+			}
+			id, err := strconv.Atoi(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("token #%d: invalid id %q", i+1, parts[2])
+			}
+			principal.EntityID = id
+		default:
+			return nil, fmt.Errorf("token #%d: unknown role %q", i+1, principal.Role)
+		}
+		tokens[parts[0]] = principal
+	}
+	return tokens, nil
+}
+
+// Action действие над ресурсом, которое проверяет Policy
+type Action string
+
+const (
+	ActionListTeachers  Action = "teachers.list"
+	ActionReadTeacher   Action = "teachers.read"
+	ActionCreateTeacher Action = "teachers.create"
+	ActionUpdateTeacher Action = "teachers.update"
+	ActionDeleteTeacher Action = "teachers.delete"
+
+	ActionListStudents  Action = "students.list"
+	ActionReadStudent   Action = "students.read"
+	ActionCreateStudent Action = "students.create"
+	ActionUpdateStudent Action = "students.update"
+	ActionDeleteStudent Action = "students.delete"
+
+	ActionListCourses  Action = "courses.list"
+	ActionReadCourse   Action = "courses.read"
+	ActionCreateCourse Action = "courses.create"
+	ActionUpdateCourse Action = "courses.update"
+	ActionDeleteCourse Action = "courses.delete"
+)
+
+// Resource владельцы записи, к которой относится действие
+type Resource struct {
+	TeacherID int
+	StudentID int
+}
+
+// ErrForbidden действие запрещено политикой
+var ErrForbidden = errors.New("access denied")
+
+// Policy решает, может ли principal выполнить action над resource
+type Policy interface {
+	Authorize(principal Principal, action Action, resource Resource) error
+}
+
+// RolePolicy правила доступа по ролям: администратор управляет всем,
+// преподаватель читает каталог и редактирует свои курсы, студент читает каталог и свой профиль
+type RolePolicy struct{}
+
+var rolePolicyRules = map[Action]func(Principal, Resource) bool{
+	ActionListTeachers: anyRole,
+	ActionReadTeacher:  anyRole,
+	ActionListCourses:  anyRole,
+	ActionReadCourse:   anyRole,
+
+	ActionReadStudent: func(p Principal, r Resource) bool {
+		return p.Role == RoleStudent && p.EntityID == r.StudentID
+	},
+	ActionUpdateCourse: func(p Principal, r Resource) bool {
+		return p.Role == RoleTeacher && p.EntityID == r.TeacherID
+	},
+}
+
+func (RolePolicy) Authorize(principal Principal, action Action, resource Resource) error {
+	if principal.Role == RoleAdmin {
+		return nil
+	}
+	if allowed, ok := rolePolicyRules[action]; ok && allowed(principal, resource) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func anyRole(p Principal, r Resource) bool {
+	return p.Role == RoleTeacher || p.Role == RoleStudent
+}
+
+// authorize определяет пользователя по заголовку Authorization: Bearer <token> и проверяет
+// действие по политике. При отказе отвечает 401 или 403 и возвращает false
+func (c *Controller) authorize(w http.ResponseWriter, r *http.Request, action Action, resource Resource) bool {
+	principal, ok := c.authenticate(w, r)
+	if !ok {
+		return false
+	}
+	if err := c.allow(principal, action, resource); err != nil {
+		respondForbidden(w)
+		return false
+	}
+	return true
+}
+
+// respondForbidden отвечает 403 на действие, запрещенное политикой
+func respondForbidden(w http.ResponseWriter) {
+	utils.RespondWithError(w, http.StatusForbidden, "Доступ запрещен")
+}
+
+// authenticate определяет пользователя по заголовку Authorization: Bearer <token>.
+// Без действующего токена отвечает 401 и возвращает false
+func (c *Controller) authenticate(w http.ResponseWriter, r *http.Request) (Principal, bool) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	principal, ok := c.tokens[token]
+	if !strings.EqualFold(scheme, "Bearer") || !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		utils.RespondWithError(w, http.StatusUnauthorized, "Требуется токен доступа")
+		return Principal{}, false
+	}
+	return principal, true
+}
+
+// allow проверяет действие по политике и записывает отказ в лог; ответ не отправляет,
+// поэтому ее можно вызывать под блокировкой хранилища
+func (c *Controller) allow(principal Principal, action Action, resource Resource) error {
+	if err := c.policy.Authorize(principal, action, resource); err != nil {
+		c.logger.Warn("access denied", "user", principal.Name, "role", principal.Role, "action", string(action),
+			"teacher_id", resource.TeacherID, "student_id", resource.StudentID)
+		return err
+	}
+	return nil
+}
@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
-	"Laba2/controllers" 
-	"Laba2/service"     
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"Laba2/controllers"
 	"Laba2/models"
+	"Laba2/service"
 )
 
+// shutdownTimeout сколько сервер ждет завершения текущих запросов при остановке
+const shutdownTimeout = 10 * time.Second
+
 func initializeData(service *service.Service) {
 	// Создаем преподавателей
 	teacher1 := models.Teacher{Name: "Alex Kov", Email: "alex.doe@gmail.com"}
@@ -28,29 +39,119 @@ func initializeData(service *service.Service) {
 	service.CreateStudent(student2)
 }
 
+// storeOptions читает настройки хранения из окружения:
+// LABA2_DATA_DIR — каталог журнала и снимков (без него данные живут только в памяти),
+// LABA2_FSYNC=always|interval|never, LABA2_FSYNC_INTERVAL и LABA2_SNAPSHOT_INTERVAL — длительности вида 1s, 5m
+func storeOptions(logger *slog.Logger) (service.Options, error) {
+	options := service.Options{
+		Dir:              os.Getenv("LABA2_DATA_DIR"),
+		Sync:             service.SyncPolicy(os.Getenv("LABA2_FSYNC")),
+		SnapshotInterval: 5 * time.Minute,
+		Logger:           logger,
+	}
+	for name, target := range map[string]*time.Duration{
+		"LABA2_FSYNC_INTERVAL":    &options.SyncInterval,
+		"LABA2_SNAPSHOT_INTERVAL": &options.SnapshotInterval,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return options, fmt.Errorf("%s must be a non-negative duration such as 30s", name)
+		}
+		*target = d
+	}
+	return options, nil
+}
+
 func main() {
 	// Создание экземпляра источника данных и сервиса
-	dataSource := service.NewDataSource()
-	service := service.NewService(dataSource)
-	controller := controllers.NewController(service)
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	options, err := storeOptions(logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	dataSource, err := service.NewDataSource(options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	service := service.NewService(dataSource, logger)
 
-	initializeData(service)
+	// Токены доступа: LABA2_TOKENS="token:admin,token2:teacher:1,token3:student:2"
+	tokens, err := controllers.ParseTokens(os.Getenv("LABA2_TOKENS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(tokens) == 0 {
+		logger.Warn("LABA2_TOKENS is not set, every request will be rejected with 401")
+	}
+	controller := controllers.NewController(service, tokens, controllers.RolePolicy{}, logger)
+
+	// Демонстрационные данные добавляются только в пустое хранилище
+	if page, _ := service.GetAllTeachers(models.ListParams{Limit: 1}); page.Total == 0 {
+		initializeData(service)
+	}
 	// Регистрация обработчиков маршрутов
 	http.HandleFunc("/teachers", controller.GetAllTeachersHandler)
+	http.HandleFunc("/teachers/get", controller.GetTeacherHandler)
 	http.HandleFunc("/teachers/create", controller.CreateTeacherHandler)
 	http.HandleFunc("/teachers/update", controller.UpdateTeacherHandler)
+	http.HandleFunc("PATCH /teachers/update", controller.PatchTeacherHandler)
 	http.HandleFunc("/teachers/delete", controller.DeleteTeacherHandler)
 
 	http.HandleFunc("/courses", controller.GetAllCoursesHandler)
+	http.HandleFunc("/courses/get", controller.GetCourseHandler)
 	http.HandleFunc("/courses/create", controller.CreateCourseHandler)
 	http.HandleFunc("/courses/update", controller.UpdateCourseHandler)
+	http.HandleFunc("PATCH /courses/update", controller.PatchCourseHandler)
 	http.HandleFunc("/courses/delete", controller.DeleteCourseHandler)
 
 	http.HandleFunc("/students", controller.GetAllStudentsHandler)
+	http.HandleFunc("/students/get", controller.GetStudentHandler)
 	http.HandleFunc("/students/create", controller.CreateStudentHandler)
 	http.HandleFunc("/students/update", controller.UpdateStudentHandler)
+	http.HandleFunc("PATCH /students/update", controller.PatchStudentHandler)
 	http.HandleFunc("/students/delete", controller.DeleteStudentHandler)
 
 	// Запуск сервера на порту 8080
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	server := &http.Server{
+		Addr:              ":8080",
+		ReadHeaderTimeout: 5 * time.Second,
+		IdleTimeout:       time.Minute,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		logger.Error("server failed", "error", err)
+		exitCode = 1
+	case <-ctx.Done():
+		// Повторный сигнал завершает процесс сразу
+		stop()
+		// Сервер перестает принимать соединения и ждет текущие запросы, чтобы их изменения
+		// попали в журнал до снимка
+		logger.Info("shutting down: draining in-flight requests", "timeout", shutdownTimeout.String())
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("drain period expired, closing remaining connections", "error", err)
+			server.Close()
+		}
+		cancel()
+	}
+
+	// При остановке журнал сбрасывается на диск и сохраняется снимок
+	if err := dataSource.Close(); err != nil {
+		logger.Error("closing data source", "error", err)
+		exitCode = 1
+	}
+	os.Exit(exitCode)
 }
@@ -21,3 +21,26 @@ type Student struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
 }
+
+// ListParams параметры выборки списка: страница (limit/offset или cursor),
+// сортировка и фильтры по префиксу
+type ListParams struct {
+	Limit  int
+	Offset int
+	Cursor string
+	Sort   string
+	Desc   bool
+	// Name фильтр по префиксу имени (для курсов — названия)
+	Name string
+	// Email фильтр по префиксу email
+	Email string
+}
+
+// Page страница списка с общим числом записей, подходящих под фильтры
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "Laba2/models"
+)
+
+// ErrStorage изменение не удалось сохранить на диск; запись в памяти осталась прежней
+var ErrStorage = errors.New("storage failure")
+
+// SyncPolicy определяет, когда журнал сбрасывается на диск (fsync)
+type SyncPolicy string
+
+const (
+	// SyncAlways сбрасывает журнал после каждой записи: подтвержденное изменение не теряется
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval сбрасывает журнал раз в Options.SyncInterval; при сбое ОС теряется не больше интервала
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever оставляет сброс операционной системе
+	SyncNever SyncPolicy = "never"
+)
+
+// Options настройки хранения DataSource. При пустом Dir данные живут только в памяти
+type Options struct {
+	// Dir каталог журнала и снимков
+	Dir string
+	// Sync политика сброса журнала, по умолчанию SyncAlways
+	Sync SyncPolicy
+	// SyncInterval период сброса для SyncInterval, по умолчанию секунда
+	SyncInterval time.Duration
+	// SnapshotInterval период снимков, после которых журнал начинается заново;
+	// 0 — снимок делается только при Close
+	SnapshotInterval time.Duration
+	// Logger получает предупреждения о поврежденном хвосте журнала и ошибки фоновых снимков
+	Logger *slog.Logger
+}
+
+// DataSource объект для хранения коллекции экземпляров сущностей.
+// Каждая коллекция защищена своей блокировкой, поэтому DataSource можно
+// использовать из нескольких обработчиков одновременно. Если нужны две блокировки,
+// они берутся в порядке courses, teachers, students (см. PatchCourse и snapshot);
+// блокировка журнала всегда берется последней
+type DataSource struct {
+	teachers *table[Teacher]
+	courses  *table[Course]
+	students *table[Student]
+
+	options Options
+	// journal nil, если данные хранятся только в памяти
+	journal *journal
+	// snapshotMu не дает двум снимкам писать файл одновременно
+	snapshotMu sync.Mutex
+	// snapshotSeq номер последней записи журнала, вошедшей в снимок на диске
+	snapshotSeq uint64
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// NewDataSource создает новый экземпляр DataSource. Если задан options.Dir,
+// состояние восстанавливается из последнего снимка и журнала, а все изменения
+// дописываются в журнал
+func NewDataSource(options Options) (*DataSource, error) {
+	if options.Sync == "" {
+		options.Sync = SyncAlways
+	}
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = time.Second
+	}
+	if options.Logger == nil {
+		options.Logger = slog.Default()
+	}
+	switch options.Sync {
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("unknown sync policy %q: must be always, interval or never", options.Sync)
+	}
+
+	ds := &DataSource{options: options, stop: make(chan struct{})}
+	ds.teachers = newTable[Teacher]("teacher", nil)
+	ds.courses = newTable[Course]("course", nil)
+	ds.students = newTable[Student]("student", nil)
+	if options.Dir == "" {
+		return ds, nil
+	}
+
+	if err := os.MkdirAll(options.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	j, err := ds.restore()
+	if err != nil {
+		return nil, err
+	}
+	ds.journal = j
+	ds.teachers.journal, ds.courses.journal, ds.students.journal = j, j, j
+
+	if options.Sync == SyncInterval {
+		ds.every(options.SyncInterval, "journal sync failed", j.sync)
+	}
+	if options.SnapshotInterval > 0 {
+		ds.every(options.SnapshotInterval, "snapshot failed", ds.snapshot)
+	}
+	return ds, nil
+}
+
+// every запускает fn с периодом interval до вызова Close
+func (ds *DataSource) every(interval time.Duration, failure string, fn func() error) {
+	ds.wg.Add(1)
+	go func() {
+		defer ds.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ds.stop:
+				return
+			case <-ticker.C:
+				if err := fn(); err != nil {
+					ds.options.Logger.Error(failure, "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// Close останавливает фоновые задачи, делает снимок и закрывает журнал.
+// Для DataSource без каталога ничего не делает
+func (ds *DataSource) Close() error {
+	if ds.journal == nil {
+		return nil
+	}
+	close(ds.stop)
+	ds.wg.Wait()
+	err := ds.snapshot()
+	return errors.Join(err, ds.journal.close())
+}
+
+// table коллекция записей одного типа. Модели не содержат ссылочных полей,
+// поэтому записи хранятся и отдаются по значению: вызывающий получает копию
+// и не может изменить хранилище в обход блокировки.
+// Изменение сначала пишется в журнал и только затем применяется в памяти
+type table[T any] struct {
+	mu   sync.RWMutex
+	rows map[int]T
+	// next следующий ID; последовательность своя у каждого DataSource
+	next atomic.Int64
+	// entity имя сущности в записях журнала
+	entity  string
+	journal *journal
+}
+
+func newTable[T any](entity string, journal *journal) *table[T] {
+	return &table[T]{rows: make(map[int]T), entity: entity, journal: journal}
+}
+
+// get возвращает запись по ID
+func (t *table[T]) get(id int) (T, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	item, ok := t.rows[id]
+	return item, ok
+}
+
+// list возвращает новый срез со всеми записями
+func (t *table[T]) list() []T {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	items := make([]T, 0, len(t.rows))
+	for _, item := range t.rows {
+		items = append(items, item)
+	}
+	return items
+}
+
+// insert выделяет ID и сохраняет запись, построенную build по этому ID
+func (t *table[T]) insert(build func(id int) T) (T, error) {
+	id := int(t.next.Add(1) - 1)
+	item := build(id)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.journal.put(t.entity, id, item); err != nil {
+		var zero T
+		return zero, err
+	}
+	t.rows[id] = item
+	return item, nil
+}
+
+// replace заменяет существующую запись; false, если записи с таким ID нет
+func (t *table[T]) replace(id int, item T) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rows[id]; !ok {
+		return false, nil
+	}
+	if err := t.journal.put(t.entity, id, item); err != nil {
+		return true, err
+	}
+	t.rows[id] = item
+	return true, nil
+}
+
+// modify заменяет запись результатом fn, удерживая блокировку, так что
+// между чтением и записью никто не изменит запись. Если fn вернула ошибку,
+// запись остается прежней; если записи нет, возвращается notFound
+func (t *table[T]) modify(id int, notFound error, fn func(T) (T, error)) (T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var zero T
+	current, ok := t.rows[id]
+	if !ok {
+		return zero, notFound
+	}
+	item, err := fn(current)
+	if err != nil {
+		return zero, err
+	}
+	if err := t.journal.put(t.entity, id, item); err != nil {
+		return zero, err
+	}
+	t.rows[id] = item
+	return item, nil
+}
+
+// remove удаляет запись; удаление отсутствующей записи не считается ошибкой
+// и в журнал не попадает
+func (t *table[T]) remove(id int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rows[id]; !ok {
+		return nil
+	}
+	if err := t.journal.delete(t.entity, id); err != nil {
+		return err
+	}
+	delete(t.rows, id)
+	return nil
+}
+
+// load применяет запись журнала при восстановлении и сдвигает
+// последовательность ID за восстановленную запись
+func (t *table[T]) load(rec record) error {
+	if rec.Op == opDelete {
+		delete(t.rows, rec.ID)
+	} else {
+		var item T
+		if err := json.Unmarshal(rec.Data, &item); err != nil {
+			return fmt.Errorf("%s %d: %w", t.entity, rec.ID, err)
+		}
+		t.rows[rec.ID] = item
+	}
+	t.reserve(rec.ID + 1)
+	return nil
+}
+
+// reserve гарантирует, что следующий выданный ID не меньше next
+func (t *table[T]) reserve(next int) {
+	if int(t.next.Load()) < next {
+		t.next.Store(int64(next))
+	}
+}
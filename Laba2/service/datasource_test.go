@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	. "Laba2/models"
+)
+
+// Тесты рассчитаны на запуск с детектором гонок: go test -race ./service
+
+const workers = 64
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	ds, err := NewDataSource(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewService(ds, testLogger)
+}
+
+// parallel запускает fn в workers горутинах и ждет их завершения
+func parallel(fn func(i int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentCreateAllocatesUniqueIDs(t *testing.T) {
+	s := newTestService(t)
+	parallel(func(i int) {
+		s.CreateTeacher(Teacher{Name: fmt.Sprintf("teacher %d", i), Email: "t@example.com"})
+		s.CreateStudent(Student{Name: fmt.Sprintf("student %d", i), Email: "s@example.com"})
+	})
+
+	teachers, err := s.GetAllTeachers(ListParams{Limit: maxPageLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if teachers.Total != workers {
+		t.Fatalf("teachers total = %d, want %d", teachers.Total, workers)
+	}
+	for i, teacher := range teachers.Items {
+		if teacher.ID != i {
+			t.Fatalf("teacher ids are not a dense sequence: position %d has id %d", i, teacher.ID)
+		}
+	}
+	students, err := s.GetAllStudents(ListParams{Limit: maxPageLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if students.Total != workers {
+		t.Fatalf("students total = %d, want %d", students.Total, workers)
+	}
+}
+
+func TestDataSourcesHaveIndependentSequences(t *testing.T) {
+	first, second := newTestService(t), newTestService(t)
+	first.CreateTeacher(Teacher{Name: "a", Email: "a@example.com"})
+	first.CreateTeacher(Teacher{Name: "b", Email: "b@example.com"})
+	second.CreateTeacher(Teacher{Name: "c", Email: "c@example.com"})
+
+	if _, err := second.GetTeacherByID(0); err != nil {
+		t.Fatalf("second data source must start from id 0: %v", err)
+	}
+	if _, err := second.GetTeacherByID(1); err == nil {
+		t.Fatal("second data source got an id allocated by the first one")
+	}
+}
+
+func TestConcurrentModifyIsAtomic(t *testing.T) {
+	s := newTestService(t)
+	s.CreateCourse(Course{Title: "course", Price: 0})
+
+	parallel(func(int) {
+		_, err := s.dataSource.courses.modify(0, nil, func(c Course) (Course, error) {
+			c.Price++
+			return c, nil
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	course, err := s.GetCourseByID(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if course.Price != workers {
+		t.Fatalf("price = %v, want %d: concurrent updates were lost", course.Price, workers)
+	}
+}
+
+func TestConcurrentPatchKeepsRecordValid(t *testing.T) {
+	s := newTestService(t)
+	s.CreateTeacher(Teacher{Name: "owner", Email: "owner@example.com"})
+	s.CreateCourse(Course{Title: "course", TeacherID: 0})
+
+	allow := func(current, patched Course) error { return nil }
+	parallel(func(i int) {
+		if i%2 == 0 {
+			s.PatchCourse(0, MergePatch{"title": fmt.Sprintf("title %d", i)}, allow)
+		} else {
+			// Невалидный патч не должен оставлять следов в хранилище
+			s.PatchCourse(0, MergePatch{"title": ""}, allow)
+		}
+		s.PatchTeacher(0, MergePatch{"name": fmt.Sprintf("name %d", i)})
+	})
+
+	course, err := s.GetCourseByID(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if course.Title == "" {
+		t.Fatal("invalid patch was stored")
+	}
+}
+
+func TestConcurrentMixedOperations(t *testing.T) {
+	s := newTestService(t)
+	for i := 0; i < workers; i++ {
+		s.CreateTeacher(Teacher{Name: fmt.Sprintf("teacher %d", i), Email: "t@example.com"})
+		s.CreateStudent(Student{Name: fmt.Sprintf("student %d", i), Email: "s@example.com"})
+		s.CreateCourse(Course{Title: fmt.Sprintf("course %d", i), TeacherID: i})
+	}
+
+	parallel(func(i int) {
+		id := (i * 7) % workers
+		switch i % 4 {
+		case 0:
+			s.GetTeacherByID(id)
+			s.GetAllCourses(ListParams{Sort: "title"})
+			s.UpdateStudent(Student{ID: id, Name: "updated", Email: "u@example.com"})
+		case 1:
+			s.PatchStudent(id, MergePatch{"email": "p@example.com"})
+			s.GetAllStudents(ListParams{Name: "stud"})
+		case 2:
+			s.UpdateCourse(Course{ID: id, Title: "updated", TeacherID: id}, func(_, _ Course) error { return nil })
+			s.DeleteTeacher(id)
+			s.GetAllTeachers(ListParams{})
+		case 3:
+			s.CreateTeacher(Teacher{Name: "new", Email: "n@example.com"})
+			s.DeleteStudent(id)
+			s.GetCourseByID(id)
+		}
+	})
+}
+
+func TestReadsReturnCopies(t *testing.T) {
+	s := newTestService(t)
+	s.CreateStudent(Student{Name: "original", Email: "o@example.com"})
+
+	page, err := s.GetAllStudents(ListParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page.Items[0].Name = "changed"
+	student, err := s.GetStudentByID(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	student.Name = "changed too"
+
+	stored, _ := s.dataSource.students.get(0)
+	if stored.Name != "original" {
+		t.Fatalf("stored name = %q: caller changed the store through a read result", stored.Name)
+	}
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Журнал хранится в каталоге Options.Dir сегментами wal-<seq>.log, где seq — номер
+// первой записи сегмента. Каждая запись — строка "<crc32 в hex> <JSON записи>".
+// Снимок сохраняет номер последней учтенной записи, поэтому при восстановлении
+// записи из старых сегментов, попавшие в снимок, пропускаются
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+
+	segmentPrefix = "wal-"
+	segmentSuffix = ".log"
+)
+
+// record запись журнала: новое состояние записи (put) или ее удаление (delete)
+type record struct {
+	Seq    uint64          `json:"seq"`
+	Op     string          `json:"op"`
+	Entity string          `json:"entity"`
+	ID     int             `json:"id"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// segmentFile файл сегмента, открытый для дозаписи; в тестах подменяется, чтобы имитировать сбои диска
+type segmentFile interface {
+	io.Writer
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// journal журнал изменений с дозаписью в конец. Методы nil-журнала ничего не делают,
+// так что DataSource без каталога работает без проверок
+type journal struct {
+	mu     sync.Mutex
+	dir    string
+	policy SyncPolicy
+	file   segmentFile
+	// size длина текущего сегмента; после неудачной записи файл обрезается до нее,
+	// чтобы обрывок записи не оказался в середине журнала
+	size int64
+	// seq номер последней записанной записи
+	seq uint64
+	// dirty в файле есть записи, еще не сброшенные на диск
+	dirty bool
+	// broken причина, по которой неудачную запись не удалось убрать из сегмента.
+	// Такой журнал больше не принимает записи: иначе после перезапуска восстановилось бы
+	// изменение, о сбое которого вызывающий уже узнал
+	broken error
+}
+
+// put записывает новое состояние записи
+func (j *journal) put(entity string, id int, item interface{}) error {
+	if j == nil {
+		return nil
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return j.append(record{Op: opPut, Entity: entity, ID: id, Data: data})
+}
+
+// delete записывает удаление записи
+func (j *journal) delete(entity string, id int) error {
+	if j == nil {
+		return nil
+	}
+	return j.append(record{Op: opDelete, Entity: entity, ID: id})
+}
+
+// append дописывает запись. При ошибке записи или сброса на диск (SyncAlways)
+// запись убирается из сегмента, так что ErrStorage означает, что изменения нет ни в памяти,
+// ни в журнале
+func (j *journal) append(rec record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.broken != nil {
+		return fmt.Errorf("%w: journal is read-only after a failed rollback: %v", ErrStorage, j.broken)
+	}
+	rec.Seq = j.seq + 1
+	line, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := j.file.Write(line); err != nil {
+		return j.rollback(err)
+	}
+	j.dirty = true
+	if j.policy == SyncAlways {
+		if err := j.file.Sync(); err != nil {
+			return j.rollback(err)
+		}
+		j.dirty = false
+	}
+	j.size += int64(len(line))
+	j.seq = rec.Seq
+	return nil
+}
+
+// rollback обрезает сегмент до длины перед неудачной записью и сбрасывает его на диск.
+// Если это не удалось, журнал помечается сломанным
+func (j *journal) rollback(cause error) error {
+	err := j.file.Truncate(j.size)
+	if err == nil {
+		err = j.file.Sync()
+	}
+	if err != nil {
+		j.broken = err
+		return fmt.Errorf("%w: %v (rollback failed: %v)", ErrStorage, cause, err)
+	}
+	j.dirty = false
+	return fmt.Errorf("%w: %v", ErrStorage, cause)
+}
+
+// sync сбрасывает записанное на диск
+func (j *journal) sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.syncLocked()
+}
+
+func (j *journal) syncLocked() error {
+	if !j.dirty {
+		return nil
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	j.dirty = false
+	return nil
+}
+
+// rotate закрывает текущий сегмент и начинает новый. Возвращает номер последней
+// записи старых сегментов: после снимка с этим номером они больше не нужны
+func (j *journal) rotate() (uint64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.broken != nil {
+		return 0, fmt.Errorf("%w: %v", ErrStorage, j.broken)
+	}
+	if err := j.syncLocked(); err != nil {
+		return 0, err
+	}
+	file, err := openSegment(j.dir, j.seq+1)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	j.file.Close()
+	j.file, j.size = file, 0
+	return j.seq, nil
+}
+
+func (j *journal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	err := j.syncLocked()
+	return errors.Join(err, j.file.Close())
+}
+
+// encodeRecord кодирует запись в строку журнала с контрольной суммой
+func encodeRecord(rec record) ([]byte, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Appendf(nil, "%08x %s\n", crc32.ChecksumIEEE(data), data), nil
+}
+
+// decodeRecord разбирает строку журнала без завершающего перевода строки
+func decodeRecord(line []byte) (record, error) {
+	var rec record
+	sum, data, ok := bytes.Cut(line, []byte(" "))
+	if !ok {
+		return rec, errors.New("missing checksum")
+	}
+	expected, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil || uint32(expected) != crc32.ChecksumIEEE(data) {
+		return rec, errors.New("checksum mismatch")
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, err
+	}
+	if rec.Op != opPut && rec.Op != opDelete {
+		return rec, fmt.Errorf("unknown operation %q", rec.Op)
+	}
+	return rec, nil
+}
+
+// readSegment вызывает apply для каждой записи сегмента и возвращает смещение
+// за последней целой записью. Оборванная или поврежденная запись возвращается
+// как ошибка вместе со смещением, с которого она начинается
+func readSegment(path string, apply func(record) error) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return offset, nil
+		}
+		if err == io.EOF {
+			return offset, errors.New("incomplete record")
+		}
+		if err != nil {
+			return offset, err
+		}
+		rec, err := decodeRecord(line[:len(line)-1])
+		if err != nil {
+			return offset, err
+		}
+		if err := apply(rec); err != nil {
+			return offset, err
+		}
+		offset += int64(len(line))
+	}
+}
+
+// segments возвращает начальные номера сегментов журнала по возрастанию
+func segments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var starts []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		start, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(a, b int) bool { return starts[a] < starts[b] })
+	return starts, nil
+}
+
+func segmentPath(dir string, start uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%s%020d%s", segmentPrefix, start, segmentSuffix))
+}
+
+func openSegment(dir string, start uint64) (*os.File, error) {
+	return os.OpenFile(segmentPath(dir, start), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+// restore загружает снимок, применяет записи журнала после него и открывает
+// последний сегмент для дозаписи. Поврежденный хвост последнего сегмента
+// (обычно запись, оборванная при сбое) отрезается с предупреждением в лог;
+// повреждение в середине журнала считается ошибкой, чтобы не потерять данные молча
+func (ds *DataSource) restore() (*journal, error) {
+	dir := ds.options.Dir
+	last, err := ds.loadSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{dir: dir, policy: ds.options.Sync, seq: last}
+	ds.snapshotSeq = last
+
+	starts, err := segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	apply := func(rec record) error {
+		if rec.Seq <= j.seq {
+			// Запись уже учтена снимком
+			return nil
+		}
+		j.seq = rec.Seq
+		return ds.load(rec)
+	}
+	for i, start := range starts {
+		path := segmentPath(dir, start)
+		offset, err := readSegment(path, apply)
+		if err == nil {
+			continue
+		}
+		if i != len(starts)-1 {
+			return nil, fmt.Errorf("journal %s is corrupted at byte %d: %w", path, offset, err)
+		}
+		ds.options.Logger.Warn("discarding corrupted journal tail",
+			"file", path, "offset", offset, "error", err)
+		if err := os.Truncate(path, offset); err != nil {
+			return nil, err
+		}
+	}
+
+	start := j.seq + 1
+	if len(starts) > 0 {
+		start = starts[len(starts)-1]
+	}
+	file, err := openSegment(dir, start)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	j.file, j.size = file, info.Size()
+	return j, nil
+}
+
+// load применяет запись журнала к соответствующей коллекции
+func (ds *DataSource) load(rec record) error {
+	switch rec.Entity {
+	case ds.teachers.entity:
+		return ds.teachers.load(rec)
+	case ds.courses.entity:
+		return ds.courses.load(rec)
+	case ds.students.entity:
+		return ds.students.load(rec)
+	}
+	return fmt.Errorf("unknown entity %q", rec.Entity)
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	. "Laba2/models"
+)
+
+func openDurable(t *testing.T, dir string) *Service {
+	t.Helper()
+	ds, err := NewDataSource(Options{Dir: dir, Sync: SyncNever, Logger: testLogger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewService(ds, testLogger)
+}
+
+// crash закрывает журнал без снимка, как при аварийной остановке процесса
+func crash(t *testing.T, s *Service) {
+	t.Helper()
+	if err := s.dataSource.journal.close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// state все записи хранилища, отсортированные по ID
+func state(t *testing.T, s *Service) (Page[Teacher], Page[Course], Page[Student]) {
+	t.Helper()
+	teachers, err := s.GetAllTeachers(ListParams{Limit: maxPageLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	courses, err := s.GetAllCourses(ListParams{Limit: maxPageLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	students, err := s.GetAllStudents(ListParams{Limit: maxPageLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return teachers, courses, students
+}
+
+func assertSameState(t *testing.T, want, got *Service) {
+	t.Helper()
+	wt, wc, ws := state(t, want)
+	gt, gc, gs := state(t, got)
+	if !reflect.DeepEqual(wt, gt) || !reflect.DeepEqual(wc, gc) || !reflect.DeepEqual(ws, gs) {
+		t.Fatalf("restored state differs:\nwant %v %v %v\ngot  %v %v %v", wt, wc, ws, gt, gc, gs)
+	}
+}
+
+func populate(t *testing.T, s *Service) {
+	t.Helper()
+	for i := 0; i < 3; i++ {
+		if err := s.CreateTeacher(Teacher{Name: fmt.Sprintf("teacher %d", i), Email: "t@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateStudent(Student{Name: fmt.Sprintf("student %d", i), Email: "s@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.CreateCourse(Course{Title: "course", TeacherID: 1, Price: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateStudent(Student{ID: 1, Name: "renamed", Email: "r@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PatchCourse(0, MergePatch{"price": 20}, func(_, _ Course) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteTeacher(2); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreReplaysJournal(t *testing.T) {
+	dir := t.TempDir()
+	s := openDurable(t, dir)
+	populate(t, s)
+	crash(t, s)
+
+	restored := openDurable(t, dir)
+	assertSameState(t, s, restored)
+
+	// Удаленный ID не выдается повторно
+	if err := restored.CreateTeacher(Teacher{Name: "next", Email: "n@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restored.GetTeacherByID(3); err != nil {
+		t.Fatalf("id sequence was not restored: %v", err)
+	}
+}
+
+func TestRestoreFromSnapshotAndJournal(t *testing.T) {
+	dir := t.TempDir()
+	s := openDurable(t, dir)
+	populate(t, s)
+	if err := s.dataSource.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openDurable(t, dir)
+	if err := s.DeleteStudent(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateCourse(Course{Title: "after snapshot", TeacherID: 0}); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, s)
+
+	restored := openDurable(t, dir)
+	assertSameState(t, s, restored)
+	if err := restored.dataSource.Close(); err != nil {
+		t.Fatal(err)
+	}
+	starts, err := segments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 1 {
+		t.Fatalf("snapshot must compact the journal to one segment, got %d", len(starts))
+	}
+}
+
+func TestRestoreDiscardsCorruptedTail(t *testing.T) {
+	dir := t.TempDir()
+	s := openDurable(t, dir)
+	populate(t, s)
+	crash(t, s)
+
+	starts, err := segments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := segmentPath(dir, starts[len(starts)-1])
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Запись, оборванная на середине
+	file.WriteString(`0badc0de {"seq":99,"op":"put","entity":"teacher","id":7,"da`)
+	file.Close()
+
+	restored := openDurable(t, dir)
+	assertSameState(t, s, restored)
+	if err := restored.CreateStudent(Student{Name: "after repair", Email: "a@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, restored)
+
+	again := openDurable(t, dir)
+	assertSameState(t, restored, again)
+}
+
+func TestRestoreRejectsCorruptedOlderSegment(t *testing.T) {
+	dir := t.TempDir()
+	s := openDurable(t, dir)
+	populate(t, s)
+	if _, err := s.dataSource.journal.rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CreateTeacher(Teacher{Name: "later", Email: "l@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	crash(t, s)
+
+	starts, err := segments(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(segmentPath(dir, starts[0]), []byte("garbage\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDataSource(Options{Dir: dir, Logger: testLogger}); err == nil {
+		t.Fatal("corruption in the middle of the journal must not be ignored")
+	}
+}
+
+func TestConcurrentWritesWithSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	s := openDurable(t, dir)
+	populate(t, s)
+
+	parallel(func(i int) {
+		switch i % 4 {
+		case 0:
+			if err := s.dataSource.snapshot(); err != nil {
+				t.Error(err)
+			}
+		case 1:
+			s.CreateCourse(Course{Title: fmt.Sprintf("course %d", i), TeacherID: 0})
+		case 2:
+			s.PatchStudent(i%3, MergePatch{"name": fmt.Sprintf("student %d", i)})
+		case 3:
+			s.CreateTeacher(Teacher{Name: fmt.Sprintf("teacher %d", i), Email: "t@example.com"})
+			s.DeleteTeacher(i)
+		}
+	})
+	crash(t, s)
+
+	restored := openDurable(t, dir)
+	assertSameState(t, s, restored)
+}
+
+// faultyFile сегмент журнала, операции которого завершаются заданными ошибками.
+// Неудачная запись оставляет в файле половину строки, как при нехватке места;
+// Sync завершается ошибкой syncFailures раз подряд
+type faultyFile struct {
+	*os.File
+	writeErr, syncErr, truncateErr error
+	syncFailures                   int
+}
+
+func (f *faultyFile) Write(p []byte) (int, error) {
+	if f.writeErr != nil {
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, f.writeErr
+	}
+	return f.File.Write(p)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.syncFailures > 0 {
+		f.syncFailures--
+		return f.syncErr
+	}
+	return f.File.Sync()
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.File.Truncate(size)
+}
+
+func TestFailedAppendIsNotReplayed(t *testing.T) {
+	diskErr := errors.New("disk failure")
+	tests := []struct {
+		name   string
+		fault  faultyFile
+		broken bool
+	}{
+		{"write fails", faultyFile{writeErr: diskErr}, false},
+		{"sync fails", faultyFile{syncErr: diskErr, syncFailures: 1}, false},
+		{"sync keeps failing", faultyFile{syncErr: diskErr, syncFailures: 2}, true},
+		{"rollback fails", faultyFile{writeErr: diskErr, truncateErr: diskErr}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			ds, err := NewDataSource(Options{Dir: dir, Sync: SyncAlways, Logger: testLogger})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewService(ds, testLogger)
+			populate(t, s)
+
+			j := ds.journal
+			file := j.file.(*os.File)
+			fault := tt.fault
+			fault.File = file
+			j.file = &fault
+			if err := s.CreateTeacher(Teacher{Name: "lost", Email: "l@example.com"}); !errors.Is(err, ErrStorage) {
+				t.Fatalf("err = %v, want ErrStorage", err)
+			}
+			j.file = file
+
+			err = s.CreateStudent(Student{Name: "after failure", Email: "a@example.com"})
+			if tt.broken != errors.Is(err, ErrStorage) {
+				t.Fatalf("write after the failure: err = %v, broken journal = %v", err, tt.broken)
+			}
+			crash(t, s)
+
+			restored := openDurable(t, dir)
+			assertSameState(t, s, restored)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	. "Laba2/models"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// Колонки, по которым разрешена сортировка списков
+var (
+	teacherSortColumns = []string{"id", "name", "email"}
+	studentSortColumns = []string{"id", "name", "email"}
+	courseSortColumns  = []string{"id", "title", "price", "teacher_id"}
+)
+
+// cursorPosition последняя выданная запись: значение колонки сортировки и ID для однозначности
+type cursorPosition struct {
+	Sort  string      `json:"s"`
+	Desc  bool        `json:"d,omitempty"`
+	Value interface{} `json:"v"`
+	ID    int         `json:"id"`
+}
+
+func teacherValue(t Teacher, column string) interface{} {
+	switch column {
+	case "name":
+		return t.Name
+	case "email":
+		return t.Email
+	}
+	return t.ID
+}
+
+func studentValue(s Student, column string) interface{} {
+	switch column {
+	case "name":
+		return s.Name
+	case "email":
+		return s.Email
+	}
+	return s.ID
+}
+
+func courseValue(c Course, column string) interface{} {
+	switch column {
+	case "title":
+		return c.Title
+	case "price":
+		return c.Price
+	case "teacher_id":
+		return c.TeacherID
+	}
+	return c.ID
+}
+
+// paginate фильтрует, сортирует по колонке и затем по ID и вырезает страницу.
+// Порядок не зависит от порядка обхода карты, поэтому страницы стабильны между вызовами
+func paginate[T any](items []T, params ListParams, columns []string,
+	value func(T, string) interface{}, id func(T) int, match func(T) bool) (Page[T], error) {
+	if params.Sort == "" {
+		params.Sort = "id"
+	}
+	if !containsString(columns, params.Sort) {
+		return Page[T]{}, fmt.Errorf("sort must be one of %s", strings.Join(columns, ", "))
+	}
+	if params.Limit <= 0 {
+		params.Limit = defaultPageLimit
+	}
+	if params.Limit > maxPageLimit {
+		params.Limit = maxPageLimit
+	}
+
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if match(item) {
+			filtered = append(filtered, item)
+		}
+	}
+
+	// compare сравнивает запись с позицией (значение колонки, ID) с учетом направления сортировки
+	compare := func(item T, v interface{}, itemID int) int {
+		c := compareValues(value(item, params.Sort), v)
+		if c == 0 {
+			c = compareValues(id(item), itemID)
+		}
+		if params.Desc {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		b := filtered[j]
+		return compare(filtered[i], value(b, params.Sort), id(b)) < 0
+	})
+
+	start := params.Offset
+	if params.Cursor != "" {
+		position, err := decodeCursor(params.Cursor)
+		if err != nil || position.Sort != params.Sort || position.Desc != params.Desc {
+			return Page[T]{}, errors.New("cursor is malformed or was issued for a different sort")
+		}
+		params.Offset = 0
+		start = sort.Search(len(filtered), func(i int) bool {
+			return compare(filtered[i], position.Value, position.ID) > 0
+		})
+	}
+	if start > len(filtered) {
+		start = len(filtered)
+	}
+	end := start + params.Limit
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+
+	page := Page[T]{
+		Items:  append([]T{}, filtered[start:end]...),
+		Total:  len(filtered),
+		Limit:  params.Limit,
+		Offset: params.Offset,
+	}
+	if end < len(filtered) && end > start {
+		last := filtered[end-1]
+		page.NextCursor = encodeCursor(cursorPosition{
+			Sort:  params.Sort,
+			Desc:  params.Desc,
+			Value: value(last, params.Sort),
+			ID:    id(last),
+		})
+	}
+	return page, nil
+}
+
+// hasPrefixFold проверяет префикс без учета регистра; пустой префикс подходит всегда
+func hasPrefixFold(s, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(s), strings.ToLower(prefix))
+}
+
+// compareValues сравнивает значения колонок; числа из курсора приходят как float64
+func compareValues(a, b interface{}) int {
+	if as, ok := a.(string); ok {
+		bs, _ := b.(string)
+		return strings.Compare(as, bs)
+	}
+	af, bf := toFloat(a), toFloat(b)
+	switch {
+	case af < bf:
+		return -1
+	case af > bf:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func encodeCursor(position cursorPosition) string {
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (cursorPosition, error) {
+	var position cursorPosition
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return position, err
+	}
+	err = json.Unmarshal(data, &position)
+	return position, err
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	. "Laba2/models"
+)
+
+// ErrInvalid результат изменения не прошел проверку; текст ошибки называет поле
+var ErrInvalid = errors.New("invalid value")
+
+// MergePatch частичное изменение записи в формате JSON Merge Patch (RFC 7396):
+// переданные поля заменяются, null сбрасывает поле в нулевое значение.
+// Модели плоские, поэтому слияние одноуровневое
+type MergePatch map[string]interface{}
+
+// apply применяет патч к target и записывает результат в out
+func (p MergePatch) apply(target, out interface{}) error {
+	data, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(doc, name)
+			continue
+		}
+		doc[name] = value
+	}
+
+	merged, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(merged, out); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return fmt.Errorf("%w: %s has wrong type", ErrInvalid, typeErr.Field)
+		}
+		return err
+	}
+	return nil
+}
+
+// PatchTeacher применяет патч к преподавателю и проверяет результат.
+// Чтение, проверка и запись выполняются атомарно
+func (s *Service) PatchTeacher(id int, patch MergePatch) (Teacher, error) {
+	teacher, err := s.dataSource.teachers.modify(id, fmt.Errorf("teacher %w", ErrNotFound), func(current Teacher) (Teacher, error) {
+		var teacher Teacher
+		if err := patch.apply(current, &teacher); err != nil {
+			return Teacher{}, err
+		}
+		teacher.ID = id
+		return teacher, validatePerson(teacher.Name, teacher.Email)
+	})
+	if err != nil {
+		return Teacher{}, err
+	}
+	s.logger.Info("teacher patched", "id", id)
+	return teacher, nil
+}
+
+// PatchStudent применяет патч к студенту и проверяет результат.
+// Чтение, проверка и запись выполняются атомарно
+func (s *Service) PatchStudent(id int, patch MergePatch) (Student, error) {
+	student, err := s.dataSource.students.modify(id, fmt.Errorf("student %w", ErrNotFound), func(current Student) (Student, error) {
+		var student Student
+		if err := patch.apply(current, &student); err != nil {
+			return Student{}, err
+		}
+		student.ID = id
+		return student, validatePerson(student.Name, student.Email)
+	})
+	if err != nil {
+		return Student{}, err
+	}
+	s.logger.Info("student patched", "id", id)
+	return student, nil
+}
+
+// PatchCourse применяет патч к курсу и проверяет результат. allow получает текущую
+// и измененную запись и может запретить изменение, например передачу курса другому преподавателю
+func (s *Service) PatchCourse(id int, patch MergePatch, allow func(current, patched Course) error) (Course, error) {
+	course, err := s.dataSource.courses.modify(id, fmt.Errorf("course %w", ErrNotFound), func(current Course) (Course, error) {
+		var course Course
+		if err := patch.apply(current, &course); err != nil {
+			return Course{}, err
+		}
+		course.ID = id
+		if err := allow(current, course); err != nil {
+			return Course{}, err
+		}
+		switch {
+		case strings.TrimSpace(course.Title) == "":
+			return Course{}, fmt.Errorf("%w: title is required", ErrInvalid)
+		case course.Price < 0:
+			return Course{}, fmt.Errorf("%w: price must not be negative", ErrInvalid)
+		}
+		if _, ok := s.dataSource.teachers.get(course.TeacherID); !ok {
+			return Course{}, fmt.Errorf("%w: teacher_id refers to a missing teacher", ErrInvalid)
+		}
+		return course, nil
+	})
+	if err != nil {
+		return Course{}, err
+	}
+	s.logger.Info("course patched", "id", id)
+	return course, nil
+}
+
+func validatePerson(name, email string) error {
+	switch {
+	case strings.TrimSpace(name) == "":
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	case !strings.Contains(email, "@"):
+		return fmt.Errorf("%w: email must be a valid email address", ErrInvalid)
+	}
+	return nil
+}
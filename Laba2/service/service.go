@@ -3,136 +3,163 @@ package service
 import (
 	"errors"
 	"fmt"
+	"log/slog"
+
 	. "Laba2/models"
-  )
-  
-  var (
-	TeacherID int = 0
-	StudentID int = 0
-	CourseID int = 0
-
-  )
-  
-  // DataSource объект для хранения коллекции экземпляров сущностей
-  type DataSource struct {
-	teachers map[int]Teacher
-	courses  map[int]Course
-	students map[int]Student
-  }
-  
-  // Service сервис выполнения CRUD операций
-  type Service struct {
+)
+
+// ErrNotFound запись с указанным ID не найдена; текст ошибки называет сущность
+var ErrNotFound = errors.New("not found")
+
+// Service сервис выполнения CRUD операций
+type Service struct {
 	dataSource *DataSource
-  }
-  
-  // NewDataSource создает новый экземпляр DataSource
-  func NewDataSource() *DataSource {
-	return &DataSource{
-	  teachers: make(map[int]Teacher),
-	  courses:  make(map[int]Course),
-	  students: make(map[int]Student),
-	}
-  }
-  
-  // NewService создает новый экземпляр Service
-  func NewService(dataSource *DataSource) *Service {
+	logger     *slog.Logger
+}
+
+// NewService создает новый экземпляр Service
+func NewService(dataSource *DataSource, logger *slog.Logger) *Service {
 	return &Service{
-	  dataSource: dataSource,
+		dataSource: dataSource,
+		logger:     logger,
 	}
-  }
-  
-  func (s *Service) GetAllTeachers() []Teacher {
-	res := []Teacher{}
-  
-	for _, v := range s.dataSource.teachers {
-	  res = append(res, v)
+}
+
+// GetAllTeachers возвращает страницу записей, отсортированных по params.Sort и затем по ID
+func (s *Service) GetAllTeachers(params ListParams) (Page[Teacher], error) {
+	res := s.dataSource.teachers.list()
+
+	return paginate(res, params, teacherSortColumns, teacherValue,
+		func(t Teacher) int { return t.ID },
+		func(t Teacher) bool {
+			return hasPrefixFold(t.Name, params.Name) && hasPrefixFold(t.Email, params.Email)
+		})
+}
+
+// GetAllStudents возвращает страницу записей, отсортированных по params.Sort и затем по ID
+func (s *Service) GetAllStudents(params ListParams) (Page[Student], error) {
+	res := s.dataSource.students.list()
+
+	return paginate(res, params, studentSortColumns, studentValue,
+		func(s Student) int { return s.ID },
+		func(s Student) bool {
+			return hasPrefixFold(s.Name, params.Name) && hasPrefixFold(s.Email, params.Email)
+		})
+}
+
+// GetAllCourses возвращает страницу записей, отсортированных по params.Sort и затем по ID
+func (s *Service) GetAllCourses(params ListParams) (Page[Course], error) {
+	res := s.dataSource.courses.list()
+
+	return paginate(res, params, courseSortColumns, courseValue,
+		func(c Course) int { return c.ID },
+		func(c Course) bool { return hasPrefixFold(c.Title, params.Name) })
+}
+
+// GetTeacherByID возвращает преподавателя по ID
+func (s *Service) GetTeacherByID(id int) (Teacher, error) {
+	teacher, ok := s.dataSource.teachers.get(id)
+	if !ok {
+		return Teacher{}, fmt.Errorf("teacher %w", ErrNotFound)
 	}
-  
-	return res
-  }
-  
-  func (s *Service) GetAllStudents() []Student {
-	res := []Student{}
-  
-	for _, v := range s.dataSource.students {
-	  res = append(res, v)
+	return teacher, nil
+}
+
+// GetStudentByID возвращает студента по ID
+func (s *Service) GetStudentByID(id int) (Student, error) {
+	student, ok := s.dataSource.students.get(id)
+	if !ok {
+		return Student{}, fmt.Errorf("student %w", ErrNotFound)
 	}
-  
-	return res
-  }
-  
-  func (s *Service) GetAllCourses() []Course {
-	res := []Course{}
-  
-	for _, v := range s.dataSource.courses {
-	  res = append(res, v)
+	return student, nil
+}
+
+// GetCourseByID возвращает курс по ID
+func (s *Service) GetCourseByID(id int) (Course, error) {
+	course, ok := s.dataSource.courses.get(id)
+	if !ok {
+		return Course{}, fmt.Errorf("course %w", ErrNotFound)
 	}
-  
-	return res
-  }
-
-  func (s *Service) CreateTeacher(teacher Teacher) {
-	teacher.ID = TeacherID
-	s.dataSource.teachers[TeacherID] = teacher
-	fmt.Println("new teacher created", TeacherID)
-	TeacherID++
-  }
-  
-  func (s *Service) CreateStudent(student Student) {
-	student.ID = StudentID
-	s.dataSource.students[StudentID] = student
-	fmt.Println("new student created", StudentID)
-	StudentID++
-  }
-
-  func (s *Service) CreateCourse(course Course) {
-	course.ID = CourseID
-	s.dataSource.courses[CourseID] = course
-	fmt.Println("new course created", CourseID)
-	CourseID++
-  }
-  
-  func (s *Service) UpdateTeacher(teacher Teacher) error {
-	if _, ok := s.dataSource.teachers[teacher.ID]; !ok {
-	  fmt.Println("user not found ", teacher.ID)
-	  return errors.New("user not found")
+	return course, nil
+}
+
+func (s *Service) CreateTeacher(teacher Teacher) error {
+	teacher, err := s.dataSource.teachers.insert(func(id int) Teacher {
+		teacher.ID = id
+		return teacher
+	})
+	if err != nil {
+		return err
 	}
-  
-	s.dataSource.teachers[teacher.ID] = teacher
+	s.logger.Info("teacher created", "id", teacher.ID)
 	return nil
-  }
-  
-  func (s *Service) UpdateStudent(student Student) error {
-	if _, ok := s.dataSource.students[student.ID]; !ok {
-	  fmt.Println("user not found ", student.ID)
-	  return errors.New("syudent not found")
+}
+
+func (s *Service) CreateStudent(student Student) error {
+	student, err := s.dataSource.students.insert(func(id int) Student {
+		student.ID = id
+		return student
+	})
+	if err != nil {
+		return err
 	}
-  
-	s.dataSource.students[student.ID] = student
+	s.logger.Info("student created", "id", student.ID)
 	return nil
-  }
+}
 
-  func (s *Service) UpdateCourse(course Course) error {
-	if _, ok := s.dataSource.courses[course.ID]; !ok {
-	  fmt.Println("user not found ", course.ID)
-	  return errors.New("course not found")
+func (s *Service) CreateCourse(course Course) error {
+	course, err := s.dataSource.courses.insert(func(id int) Course {
+		course.ID = id
+		return course
+	})
+	if err != nil {
+		return err
 	}
-  
-	s.dataSource.courses[course.ID] = course
+	s.logger.Info("course created", "id", course.ID)
 	return nil
-  }
-  
-  func (s *Service) DeleteTeacher(id int) {
-  
-	delete(s.dataSource.teachers, id)
-  }
-  
-  func (s *Service) DeleteStudent(id int) {
-  
-	delete(s.dataSource.students, id)
-  }
-
-  func (s *Service) DeleteCourse(id int) {
-  
-	delete(s.dataSource.courses, id)
-  }
\ No newline at end of file
+}
+
+func (s *Service) UpdateTeacher(teacher Teacher) error {
+	ok, err := s.dataSource.teachers.replace(teacher.ID, teacher)
+	if !ok {
+		s.logger.Warn("teacher not found", "id", teacher.ID)
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+	return err
+}
+
+func (s *Service) UpdateStudent(student Student) error {
+	ok, err := s.dataSource.students.replace(student.ID, student)
+	if !ok {
+		s.logger.Warn("student not found", "id", student.ID)
+		return fmt.Errorf("syudent %w", ErrNotFound)
+	}
+	return err
+}
+
+// UpdateCourse заменяет курс. allow получает текущую и новую запись под блокировкой таблицы,
+// как в PatchCourse, и может запретить изменение
+func (s *Service) UpdateCourse(course Course, allow func(current, updated Course) error) error {
+	_, err := s.dataSource.courses.modify(course.ID, fmt.Errorf("course %w", ErrNotFound), func(current Course) (Course, error) {
+		if err := allow(current, course); err != nil {
+			return Course{}, err
+		}
+		return course, nil
+	})
+	if errors.Is(err, ErrNotFound) {
+		s.logger.Warn("course not found", "id", course.ID)
+	}
+	return err
+}
+
+func (s *Service) DeleteTeacher(id int) error {
+	return s.dataSource.teachers.remove(id)
+}
+
+func (s *Service) DeleteStudent(id int) error {
+	return s.dataSource.students.remove(id)
+}
+
+func (s *Service) DeleteCourse(id int) error {
+	return s.dataSource.courses.remove(id)
+}
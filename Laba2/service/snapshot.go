@@ -0,0 +1,140 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "Laba2/models"
+)
+
+const snapshotFile = "snapshot.json"
+
+// snapshotData содержимое снимка: все коллекции, следующие ID и номер
+// последней записи журнала, вошедшей в снимок
+type snapshotData struct {
+	Seq      uint64         `json:"seq"`
+	Next     map[string]int `json:"next"`
+	Teachers []Teacher      `json:"teachers"`
+	Courses  []Course       `json:"courses"`
+	Students []Student      `json:"students"`
+}
+
+// snapshot сохраняет согласованный снимок всех коллекций и удаляет сегменты
+// журнала, которые в него вошли. На время копирования в память изменения
+// блокируются; запись файла идет уже без блокировок
+func (ds *DataSource) snapshot() error {
+	ds.snapshotMu.Lock()
+	defer ds.snapshotMu.Unlock()
+
+	ds.courses.mu.RLock()
+	ds.teachers.mu.RLock()
+	ds.students.mu.RLock()
+	data := snapshotData{
+		Next: map[string]int{
+			ds.teachers.entity: int(ds.teachers.next.Load()),
+			ds.courses.entity:  int(ds.courses.next.Load()),
+			ds.students.entity: int(ds.students.next.Load()),
+		},
+		Teachers: values(ds.teachers.rows),
+		Courses:  values(ds.courses.rows),
+		Students: values(ds.students.rows),
+	}
+	seq, err := ds.journal.rotate()
+	ds.students.mu.RUnlock()
+	ds.teachers.mu.RUnlock()
+	ds.courses.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if seq == ds.snapshotSeq {
+		// С прошлого снимка ничего не изменилось
+		return nil
+	}
+	data.Seq = seq
+
+	if err := writeFileAtomic(filepath.Join(ds.options.Dir, snapshotFile), data); err != nil {
+		return fmt.Errorf("%w: %v", ErrStorage, err)
+	}
+	starts, err := segments(ds.options.Dir)
+	if err != nil {
+		return err
+	}
+	for _, start := range starts {
+		if start <= seq {
+			os.Remove(segmentPath(ds.options.Dir, start))
+		}
+	}
+	ds.snapshotSeq = seq
+	return nil
+}
+
+// loadSnapshot восстанавливает коллекции из снимка и возвращает номер последней
+// вошедшей в него записи журнала; без снимка возвращает 0
+func (ds *DataSource) loadSnapshot() (uint64, error) {
+	content, err := os.ReadFile(filepath.Join(ds.options.Dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var data snapshotData
+	if err := json.Unmarshal(content, &data); err != nil {
+		return 0, fmt.Errorf("snapshot %s is corrupted: %w", snapshotFile, err)
+	}
+	for _, t := range data.Teachers {
+		ds.teachers.rows[t.ID] = t
+	}
+	for _, c := range data.Courses {
+		ds.courses.rows[c.ID] = c
+	}
+	for _, s := range data.Students {
+		ds.students.rows[s.ID] = s
+	}
+	ds.teachers.reserve(data.Next[ds.teachers.entity])
+	ds.courses.reserve(data.Next[ds.courses.entity])
+	ds.students.reserve(data.Next[ds.students.entity])
+	return data.Seq, nil
+}
+
+func values[T any](rows map[int]T) []T {
+	items := make([]T, 0, len(rows))
+	for _, item := range rows {
+		items = append(items, item)
+	}
+	return items
+}
+
+// writeFileAtomic записывает v во временный файл, сбрасывает его на диск и
+// переименовывает поверх path, так что после сбоя остается либо старый, либо новый снимок
+func writeFileAtomic(path string, v interface{}) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(file).Encode(v); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	// Переименование тоже должно дойти до диска
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
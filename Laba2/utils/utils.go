@@ -2,7 +2,13 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"Laba2/models"
 )
 
 // RespondWithError отправляет ответ с ошибкой в формате JSON
@@ -22,3 +28,34 @@ func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.WriteHeader(code)
 	w.Write(response)
 }
+
+// ParseListParams читает параметры списка из строки запроса:
+// limit, offset, cursor, sort, order=asc|desc, name, email
+func ParseListParams(query url.Values) (models.ListParams, error) {
+	params := models.ListParams{
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+		Name:   query.Get("name"),
+		Email:  query.Get("email"),
+	}
+
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if params.Limit, err = strconv.Atoi(v); err != nil || params.Limit <= 0 {
+			return models.ListParams{}, errors.New("limit must be a positive integer")
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if params.Offset, err = strconv.Atoi(v); err != nil || params.Offset < 0 {
+			return models.ListParams{}, errors.New("offset must be a non-negative integer")
+		}
+	}
+	switch strings.ToLower(query.Get("order")) {
+	case "", "asc":
+	case "desc":
+		params.Desc = true
+	default:
+		return models.ListParams{}, errors.New("order must be asc or desc")
+	}
+	return params, nil
+}
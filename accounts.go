@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Назначение одноразовых токенов учетных записей
+const (
+	tokenPurposeInvite = "invite"
+	tokenPurposeReset  = "reset"
+)
+
+// UserStore хранилище учетных записей и их одноразовых токенов
+type UserStore interface {
+	CreateUser(ctx context.Context, user User) (User, error)
+	GetUserByID(ctx context.Context, id int) (User, error)
+	GetUserByUsername(ctx context.Context, username string) (User, error)
+	// SetPassword записывает хеш пароля, запоминает время смены и снимает блокировку входа
+	SetPassword(ctx context.Context, userID int, passwordHash string) error
+	// RecordLoginFailure увеличивает счетчик неудачных входов; при достижении maxFailures
+	// счетчик обнуляется, а вход блокируется до lockUntil
+	RecordLoginFailure(ctx context.Context, userID, maxFailures int, lockUntil time.Time) error
+	ResetLoginFailures(ctx context.Context, userID int) error
+	// RenameTeacherUser и RenameStudentUser меняют имя учетной записи преподавателя или студента
+	// вслед за его email; если учетной записи нет, ничего не делают.
+	// Имя занято другой учетной записью — ErrUserExists
+	RenameTeacherUser(ctx context.Context, teacherID int, username string) error
+	RenameStudentUser(ctx context.Context, studentID int, username string) error
+
+	CreateUserToken(ctx context.Context, token UserToken) error
+	// ConsumeUserToken помечает неиспользованный и неистекший токен использованным
+	// и возвращает ID пользователя; иначе ErrInvalidUserToken
+	ConsumeUserToken(ctx context.Context, hash, purpose string, now time.Time) (int, error)
+}
+
+// dummyPasswordHash сравнивается с паролем, когда пользователь не найден,
+// чтобы по времени ответа нельзя было узнать, существует ли учетная запись
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// Accounts учетные записи: вход, приглашения, сброс пароля и блокировка после неудачных попыток
+type Accounts struct {
+	users   UserStore
+	service *Service
+	mailer  Mailer
+	cfg     AccountsConfig
+	logger  *slog.Logger
+}
+
+// NewAccounts создает новый экземпляр Accounts
+func NewAccounts(users UserStore, service *Service, mailer Mailer, cfg AccountsConfig, logger *slog.Logger) *Accounts {
+	return &Accounts{
+		users:   users,
+		service: service,
+		mailer:  mailer,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+// EnsureAdmin создает администратора username с паролем password, если такого пользователя еще нет.
+// Пароль существующего пользователя не меняется
+func (a *Accounts) EnsureAdmin(ctx context.Context, username, password string) error {
+	_, err := a.users.GetUserByUsername(ctx, username)
+	if err == nil || !errors.Is(err, ErrUserNotFound) {
+		return domainError(err)
+	}
+	hash, err := a.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	if _, err := a.users.CreateUser(ctx, User{Username: username, PasswordHash: hash, Role: RoleAdmin}); err != nil {
+		return domainError(err)
+	}
+	a.logger.Info("admin account created", "username", username)
+	return nil
+}
+
+// Authenticate проверяет имя пользователя и пароль. Реализует Credentials для /auth/login
+func (a *Accounts) Authenticate(ctx context.Context, username, password string) (Principal, error) {
+	user, err := a.users.GetUserByUsername(ctx, username)
+	if errors.Is(err, ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return Principal{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return Principal{}, domainError(err)
+	}
+
+	now := time.Now()
+	if now.Before(user.LockedUntil) {
+		return Principal{}, ErrAccountLocked
+	}
+	if user.PasswordHash == "" {
+		// Приглашение еще не принято
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return Principal{}, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		lockUntil := now.Add(a.cfg.LockoutDuration.Duration)
+		if err := a.users.RecordLoginFailure(ctx, user.ID, a.cfg.MaxFailedLogins, lockUntil); err != nil {
+			return Principal{}, domainError(err)
+		}
+		if user.FailedLogins+1 >= a.cfg.MaxFailedLogins {
+			a.logger.Warn("account locked after repeated login failures", "username", username, "until", lockUntil)
+		}
+		return Principal{}, ErrInvalidCredentials
+	}
+
+	if user.FailedLogins > 0 {
+		if err := a.users.ResetLoginFailures(ctx, user.ID); err != nil {
+			return Principal{}, domainError(err)
+		}
+	}
+	return user.principal(), nil
+}
+
+// Reauthenticate проверяет владельца refresh-токена, выданного в issuedAt. Реализует
+// Credentials для /auth/refresh: после смены или сброса пароля ранее выданные
+// refresh-токены перестают действовать
+func (a *Accounts) Reauthenticate(ctx context.Context, subject string, issuedAt time.Time) (Principal, error) {
+	user, err := a.users.GetUserByUsername(ctx, subject)
+	if errors.Is(err, ErrUserNotFound) {
+		return Principal{}, ErrTokenRevoked
+	}
+	if err != nil {
+		return Principal{}, domainError(err)
+	}
+	if time.Now().Before(user.LockedUntil) {
+		return Principal{}, ErrAccountLocked
+	}
+	// iat хранится с точностью до секунды
+	if user.PasswordHash == "" || user.PasswordChangedAt.Unix() > issuedAt.Unix() {
+		return Principal{}, ErrTokenRevoked
+	}
+	return user.principal(), nil
+}
+
+func (u User) principal() Principal {
+	p := Principal{Subject: u.Username, Role: u.Role}
+	switch u.Role {
+	case RoleTeacher:
+		p.EntityID = u.TeacherID
+	case RoleStudent:
+		p.EntityID = u.StudentID
+	}
+	return p
+}
+
+// InviteTeacher создает учетную запись преподавателя без пароля и отправляет ссылку-приглашение
+func (a *Accounts) InviteTeacher(ctx context.Context, teacherID int) (User, error) {
+	teacher, err := a.service.GetTeacherByID(ctx, teacherID)
+	if err != nil {
+		return User{}, err
+	}
+	return a.invite(ctx, User{Username: teacher.Email, Role: RoleTeacher, TeacherID: teacher.ID}, teacher.Name)
+}
+
+// InviteStudent создает учетную запись студента без пароля и отправляет ссылку-приглашение
+func (a *Accounts) InviteStudent(ctx context.Context, studentID int) (User, error) {
+	student, err := a.service.GetStudentByID(ctx, studentID)
+	if err != nil {
+		return User{}, err
+	}
+	return a.invite(ctx, User{Username: student.Email, Role: RoleStudent, StudentID: student.ID}, student.Name)
+}
+
+// invite создает учетную запись без пароля и отправляет ссылку-приглашение. Если приглашение
+// еще не принято (письмо не дошло или ссылка истекла), повторный вызов отправляет новую ссылку
+// той же учетной записи; после принятия приглашения — ErrUserExists
+func (a *Accounts) invite(ctx context.Context, user User, name string) (User, error) {
+	existing, err := a.users.GetUserByUsername(ctx, user.Username)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		if user, err = a.users.CreateUser(ctx, user); err != nil {
+			return User{}, domainError(err)
+		}
+	case err != nil:
+		return User{}, domainError(err)
+	case existing.PasswordHash == "" && existing.TeacherID == user.TeacherID && existing.StudentID == user.StudentID:
+		user = existing
+	default:
+		return User{}, ErrUserExists
+	}
+	token, err := a.issueToken(ctx, user.ID, tokenPurposeInvite, a.cfg.InviteTTL.Duration)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = a.mailer.Send(Mail{
+		To:      user.Username,
+		Subject: "Приглашение в систему курсов",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nДля вас создана учетная запись %s.\n"+
+			"Чтобы задать пароль, перейдите по ссылке (действует до %s):\n%s\n",
+			name, user.Username, time.Now().Add(a.cfg.InviteTTL.Duration).Format(time.RFC1123),
+			a.link("/accounts/invitations/accept", token)),
+	})
+	if err != nil {
+		return User{}, domainError(fmt.Errorf("send invitation: %w", err))
+	}
+	a.logger.Info("invitation sent", "user_id", user.ID, "role", user.Role)
+	return user, nil
+}
+
+// AcceptInvitation задает пароль по токену приглашения
+func (a *Accounts) AcceptInvitation(ctx context.Context, token, password string) error {
+	return a.setPasswordByToken(ctx, token, tokenPurposeInvite, password)
+}
+
+// RequestPasswordReset отправляет ссылку для сброса пароля, если пользователь существует
+// и уже принял приглашение. Об отсутствии пользователя не сообщается
+func (a *Accounts) RequestPasswordReset(ctx context.Context, username string) error {
+	user, err := a.users.GetUserByUsername(ctx, strings.TrimSpace(username))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return domainError(err)
+	}
+	if user.PasswordHash == "" {
+		return nil
+	}
+
+	token, err := a.issueToken(ctx, user.ID, tokenPurposeReset, a.cfg.ResetTTL.Duration)
+	if err != nil {
+		return err
+	}
+	err = a.mailer.Send(Mail{
+		To:      user.Username,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Для учетной записи %s запрошен сброс пароля.\n"+
+			"Чтобы задать новый пароль, перейдите по ссылке (действует до %s):\n%s\n\n"+
+			"Если вы не запрашивали сброс, просто проигнорируйте это письмо.\n",
+			user.Username, time.Now().Add(a.cfg.ResetTTL.Duration).Format(time.RFC1123),
+			a.link("/accounts/password-reset/confirm", token)),
+	})
+	if err != nil {
+		return domainError(fmt.Errorf("send password reset: %w", err))
+	}
+	a.logger.Info("password reset requested", "user_id", user.ID)
+	return nil
+}
+
+// ResetPassword задает новый пароль по токену сброса
+func (a *Accounts) ResetPassword(ctx context.Context, token, password string) error {
+	return a.setPasswordByToken(ctx, token, tokenPurposeReset, password)
+}
+
+func (a *Accounts) setPasswordByToken(ctx context.Context, token, purpose, password string) error {
+	hash, err := a.hashPassword(password)
+	if err != nil {
+		return err
+	}
+	userID, err := a.users.ConsumeUserToken(ctx, hashToken(token), purpose, time.Now())
+	if err != nil {
+		return domainError(err)
+	}
+	if err := a.users.SetPassword(ctx, userID, hash); err != nil {
+		return domainError(err)
+	}
+	a.logger.Info("password set", "user_id", userID, "via", purpose)
+	return nil
+}
+
+func (a *Accounts) hashPassword(password string) (string, error) {
+	if len([]rune(password)) < a.cfg.MinPasswordLength {
+		return "", weakPassword(fmt.Sprintf("password must be at least %d characters", a.cfg.MinPasswordLength))
+	}
+	// bcrypt учитывает только первые 72 байта
+	if len(password) > 72 {
+		return "", weakPassword("password must be at most 72 bytes")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// issueToken создает одноразовый токен; в хранилище попадает только его хеш
+func (a *Accounts) issueToken(ctx context.Context, userID int, purpose string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	err := a.users.CreateUserToken(ctx, UserToken{
+		Hash:      hashToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	return token, domainError(err)
+}
+
+func (a *Accounts) link(path, token string) string {
+	return strings.TrimRight(a.cfg.BaseURL, "/") + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
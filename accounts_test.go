@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+// testMailer запоминает письма; пока fail не nil, отправка завершается этой ошибкой
+type testMailer struct {
+	sent []Mail
+	fail error
+}
+
+func (m *testMailer) Send(mail Mail) error {
+	if m.fail != nil {
+		return m.fail
+	}
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+var tokenPattern = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// lastToken возвращает токен из ссылки последнего отправленного письма
+func (m *testMailer) lastToken(t *testing.T) string {
+	t.Helper()
+	if len(m.sent) == 0 {
+		t.Fatal("no mail was sent")
+	}
+	match := tokenPattern.FindStringSubmatch(m.sent[len(m.sent)-1].Body)
+	if match == nil {
+		t.Fatal("mail has no token link")
+	}
+	return match[1]
+}
+
+func newTestAccounts(ds DataSource) (*Accounts, *Service, *testMailer) {
+	s := newTestService(ds)
+	mailer := &testMailer{}
+	return NewAccounts(ds, s, mailer, DefaultConfig().Accounts, testLogger), s, mailer
+}
+
+func TestInviteRetry(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		ctx := context.Background()
+		accounts, s, mailer := newTestAccounts(ds)
+		teacher := mustCreateTeacher(t, s, "Иван Петрович", "ivan@example.com")
+
+		mailer.fail = errors.New("smtp is down")
+		if _, err := accounts.InviteTeacher(ctx, teacher.ID); err == nil {
+			t.Fatal("invite succeeded with a failing mailer")
+		}
+
+		// Приглашение, которое не дошло, можно повторить, и каждая ссылка рабочая
+		mailer.fail = nil
+		first, err := accounts.InviteTeacher(ctx, teacher.ID)
+		if err != nil {
+			t.Fatalf("retry after mail failure: %v", err)
+		}
+		again, err := accounts.InviteTeacher(ctx, teacher.ID)
+		if err != nil {
+			t.Fatalf("second retry: %v", err)
+		}
+		if again.ID != first.ID {
+			t.Fatalf("retry created a new account %d, want %d", again.ID, first.ID)
+		}
+		if err := accounts.AcceptInvitation(ctx, mailer.lastToken(t), "correct horse"); err != nil {
+			t.Fatalf("accept: %v", err)
+		}
+
+		if _, err := accounts.InviteTeacher(ctx, teacher.ID); !errors.Is(err, ErrUserExists) {
+			t.Fatalf("invite after acceptance: err = %v, want %v", err, ErrUserExists)
+		}
+	})
+}
+
+func TestEmailChangeRenamesUser(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		ctx := context.Background()
+		accounts, s, _ := newTestAccounts(ds)
+		teacher := mustCreateTeacher(t, s, "Иван Петрович", "ivan@example.com")
+		student := mustCreateStudent(t, s, "Анна", "anna@example.com")
+		if _, err := accounts.InviteTeacher(ctx, teacher.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := accounts.InviteStudent(ctx, student.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name    string
+			update  func() error
+			want    string
+			wantErr error
+		}{
+			{"teacher put", func() error {
+				_, err := s.UpdateTeacher(ctx, Teacher{ID: teacher.ID, Name: teacher.Name, Email: "ivan.p@example.com"})
+				return err
+			}, "ivan.p@example.com", nil},
+			{"student patch", func() error {
+				_, err := s.PatchStudent(ctx, student.ID, 0, MergePatch{"email": "anna.k@example.com"})
+				return err
+			}, "anna.k@example.com", nil},
+			{"taken by another account", func() error {
+				_, err := s.UpdateStudent(ctx, Student{ID: student.ID, Name: student.Name, Email: "ivan.p@example.com"})
+				return err
+			}, "anna.k@example.com", ErrUserExists},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if err := tt.update(); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if _, err := ds.GetUserByUsername(ctx, tt.want); err != nil {
+					t.Fatalf("user %q: %v", tt.want, err)
+				}
+			})
+		}
+
+		// Откаченное изменение не меняет и сам email
+		got, err := s.GetStudentByID(ctx, student.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != "anna.k@example.com" {
+			t.Fatalf("student email = %q after a rolled back update", got.Email)
+		}
+	})
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Типы токенов: access предъявляется в заголовке Authorization,
+// refresh обменивается на новую пару в /auth/refresh
+const (
+	tokenAccess  = "access"
+	tokenRefresh = "refresh"
+)
+
+// Claims полезная нагрузка JWT
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	EntityID  int    `json:"eid,omitempty"`
+	Type      string `json:"typ"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Principal аутентифицированный пользователь
+type Principal struct {
+	Subject string
+	Role    string
+	// EntityID ID записи преподавателя или студента, с которой связан пользователь
+	EntityID int
+}
+
+// Principal возвращает пользователя, которому выдан токен
+func (c Claims) Principal() Principal {
+	return Principal{Subject: c.Subject, Role: c.Role, EntityID: c.EntityID}
+}
+
+// Credentials проверяет имя пользователя и пароль
+type Credentials interface {
+	Authenticate(ctx context.Context, username, password string) (Principal, error)
+	// Reauthenticate проверяет, что пользователь subject, получивший токен в issuedAt,
+	// по-прежнему может входить, и возвращает его актуальные данные
+	Reauthenticate(ctx context.Context, subject string, issuedAt time.Time) (Principal, error)
+}
+
+// TokenPair ответ /auth/login и /auth/refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Auth выпускает и проверяет подписанные HS256 токены.
+// Подписывает первый ключ из auth.signing_keys, проверка принимает любой из них по kid,
+// поэтому ключ меняют так: новый ставят первым, старый оставляют до истечения его токенов
+type Auth struct {
+	keys        []SigningKey
+	issuer      string
+	accessTTL   time.Duration
+	refreshTTL  time.Duration
+	credentials Credentials
+	revoked     *revocationList
+	logger      *slog.Logger
+}
+
+// NewAuth создает новый экземпляр Auth. Без настроенных ключей генерируется временный ключ:
+// выданные токены перестанут действовать после перезапуска
+func NewAuth(cfg AuthConfig, credentials Credentials, logger *slog.Logger) *Auth {
+	keys := cfg.SigningKeys
+	if len(keys) == 0 {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		keys = []SigningKey{{ID: "ephemeral", Secret: hex.EncodeToString(secret)}}
+		logger.Warn("auth.signing_keys is not set, using an ephemeral key; tokens will not survive a restart")
+	}
+	return &Auth{
+		keys:        keys,
+		issuer:      cfg.Issuer,
+		accessTTL:   cfg.AccessTTL.Duration,
+		refreshTTL:  cfg.RefreshTTL.Duration,
+		credentials: credentials,
+		revoked:     newRevocationList(),
+		logger:      logger,
+	}
+}
+
+// Issue выпускает пару access/refresh токенов для principal
+func (a *Auth) Issue(principal Principal) (TokenPair, error) {
+	access, err := a.sign(principal, tokenAccess, a.accessTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refresh, err := a.sign(principal, tokenRefresh, a.refreshTTL)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(a.accessTTL.Seconds()),
+	}, nil
+}
+
+func (a *Auth) sign(principal Principal, typ string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	key := a.keys[0]
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT", "kid": key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(Claims{
+		Issuer:    a.issuer,
+		Subject:   principal.Subject,
+		Role:      principal.Role,
+		EntityID:  principal.EntityID,
+		Type:      typ,
+		ID:        newTokenID(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature(key.Secret, unsigned)), nil
+}
+
+// Verify проверяет подпись, издателя, тип и срок действия токена и то, что он не отозван
+func (a *Auth) Verify(token, typ string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Claims{}, ErrInvalidToken
+	}
+	key, ok := a.key(header.Kid)
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(got, signature(key.Secret, parts[0]+"."+parts[1])) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if claims.Issuer != a.issuer || claims.Type != typ || claims.ID == "" {
+		return Claims{}, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrTokenExpired
+	}
+	if a.revoked.contains(claims.ID) {
+		return Claims{}, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// Revoke отзывает токен до истечения его срока действия
+func (a *Auth) Revoke(claims Claims) {
+	a.revoked.add(claims.ID, time.Unix(claims.ExpiresAt, 0))
+}
+
+func (a *Auth) key(id string) (SigningKey, bool) {
+	for _, key := range a.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return SigningKey{}, false
+}
+
+func signature(secret, unsigned string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func newTokenID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// revocationList идентификаторы отозванных токенов. Запись хранится до истечения токена,
+// после чего он и так недействителен. Список живет в памяти процесса
+type revocationList struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+func newRevocationList() *revocationList {
+	return &revocationList{expires: make(map[string]time.Time)}
+}
+
+func (l *revocationList) add(id string, expiresAt time.Time) {
+	l.revokeOnce(id, expiresAt)
+}
+
+// revokeOnce отзывает токен и возвращает false, если он уже был отозван.
+// Проверка и запись выполняются под одной блокировкой, поэтому из параллельных
+// вызовов с одним id true получает только один
+func (l *revocationList) revokeOnce(id string, expiresAt time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for jti, at := range l.expires {
+		if now.After(at) {
+			delete(l.expires, jti)
+		}
+	}
+	if _, ok := l.expires[id]; ok {
+		return false
+	}
+	l.expires[id] = expiresAt
+	return true
+}
+
+func (l *revocationList) contains(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.expires[id]
+	return ok
+}
+
+type claimsKey struct{}
+
+// claimsFrom возвращает данные токена, проверенного Require
+func claimsFrom(ctx context.Context) (Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(Claims)
+	return claims, ok
+}
+
+// bearerToken извлекает токен из заголовка Authorization: Bearer <token>
+func bearerToken(r *http.Request) (string, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", ErrUnauthorized
+	}
+	return token, nil
+}
+
+// Require пропускает запрос к handler только с действительным access-токеном
+func (a *Auth) Require(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := bearerToken(r)
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		claims, err := a.Verify(token, tokenAccess)
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		handler(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+	}
+}
+
+// LoginHandler обменивает имя пользователя и пароль на пару токенов
+func (a *Auth) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	principal, err := a.credentials.Authenticate(r.Context(), req.Username, req.Password)
+	if err != nil {
+		a.logger.WarnContext(r.Context(), "login failed", "username", req.Username)
+		respondWithError(w, err)
+		return
+	}
+	pair, err := a.Issue(principal)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	a.logger.InfoContext(r.Context(), "login succeeded", "subject", principal.Subject)
+	respondWithJSON(w, http.StatusOK, pair)
+}
+
+// RefreshHandler выдает новую пару токенов по refresh-токену; предъявленный токен отзывается,
+// поэтому каждый refresh-токен можно использовать только один раз. Токен не принимается,
+// если учетная запись удалена, заблокирована или ее пароль сменили после выдачи токена
+func (a *Auth) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	claims, err := a.Verify(req.RefreshToken, tokenRefresh)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	principal, err := a.credentials.Reauthenticate(r.Context(), claims.Subject, time.Unix(claims.IssuedAt, 0))
+	if err != nil {
+		a.logger.WarnContext(r.Context(), "refresh rejected", "subject", claims.Subject, "error", err)
+		respondWithError(w, err)
+		return
+	}
+	// Verify пропускает параллельные запросы с одним токеном; новую пару получает только первый
+	if !a.revoked.revokeOnce(claims.ID, time.Unix(claims.ExpiresAt, 0)) {
+		respondWithError(w, ErrTokenRevoked)
+		return
+	}
+	pair, err := a.Issue(principal)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, pair)
+}
+
+// LogoutHandler отзывает access-токен из заголовка и, если передан, refresh-токен из тела
+func (a *Auth) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims, _ := claimsFrom(r.Context())
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, ErrEmptyBody) {
+		respondWithError(w, err)
+		return
+	}
+	if req.RefreshToken != "" {
+		refresh, err := a.Verify(req.RefreshToken, tokenRefresh)
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		if refresh.Subject != claims.Subject {
+			respondWithError(w, ErrInvalidToken)
+			return
+		}
+		a.Revoke(refresh)
+	}
+	a.Revoke(claims)
+	a.logger.InfoContext(r.Context(), "logout", "subject", claims.Subject)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAdmin         = "admin"
+	testAdminPassword = "adminpass123"
+)
+
+func newTestAuth(t *testing.T, ds DataSource) (*Auth, *Accounts) {
+	t.Helper()
+	accounts, _, _ := newTestAccounts(ds)
+	if err := accounts.EnsureAdmin(context.Background(), testAdmin, testAdminPassword); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig().Auth
+	cfg.SigningKeys = SigningKeys{{ID: "test", Secret: "test secret"}}
+	return NewAuth(cfg, accounts, testLogger), accounts
+}
+
+// serveJSON вызывает handler с телом body и возвращает ответ
+func serveJSON(handler http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// errorCode возвращает поле code JSON-ответа с ошибкой
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode error response %q: %v", w.Body.String(), err)
+	}
+	return body.Code
+}
+
+func TestReauthenticate(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		ctx := context.Background()
+		_, accounts := newTestAuth(t, ds)
+		admin, err := ds.GetUserByUsername(ctx, testAdmin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Сброс пароля делает недействительными refresh-токены, выданные до него
+		if err := ds.SetPassword(ctx, admin.ID, admin.PasswordHash); err != nil {
+			t.Fatal(err)
+		}
+		pending, err := ds.CreateUser(ctx, User{Username: "pending", Role: RoleAdmin})
+		if err != nil {
+			t.Fatal(err)
+		}
+		locked, err := ds.CreateUser(ctx, User{Username: "locked", PasswordHash: admin.PasswordHash, Role: RoleAdmin})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ds.RecordLoginFailure(ctx, locked.ID, 1, time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name     string
+			subject  string
+			issuedAt time.Time
+			wantErr  error
+		}{
+			{"active", testAdmin, time.Now(), nil},
+			{"issued before password change", testAdmin, time.Now().Add(-time.Hour), ErrTokenRevoked},
+			{"deleted", "nobody", time.Now(), ErrTokenRevoked},
+			{"invitation not accepted", pending.Username, time.Now(), ErrTokenRevoked},
+			{"locked", locked.Username, time.Now(), ErrAccountLocked},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				principal, err := accounts.Reauthenticate(ctx, tt.subject, tt.issuedAt)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if err == nil && principal.Subject != tt.subject {
+					t.Fatalf("subject = %q, want %q", principal.Subject, tt.subject)
+				}
+			})
+		}
+	})
+}
+
+func TestRefreshHandler(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		auth, _ := newTestAuth(t, ds)
+		w := serveJSON(auth.LoginHandler, http.MethodPost, "/auth/login",
+			`{"username":"`+testAdmin+`","password":"`+testAdminPassword+`"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("login status = %d: %s", w.Code, w.Body)
+		}
+		var pair TokenPair
+		if err := json.Unmarshal(w.Body.Bytes(), &pair); err != nil {
+			t.Fatal(err)
+		}
+		refresh := `{"refresh_token":"` + pair.RefreshToken + `"}`
+
+		if w := serveJSON(auth.RefreshHandler, http.MethodPost, "/auth/refresh", refresh); w.Code != http.StatusOK {
+			t.Fatalf("refresh status = %d: %s", w.Code, w.Body)
+		}
+		w = serveJSON(auth.RefreshHandler, http.MethodPost, "/auth/refresh", refresh)
+		if w.Code != http.StatusUnauthorized || errorCode(t, w) != ErrTokenRevoked.Code {
+			t.Fatalf("reused refresh token: status = %d: %s", w.Code, w.Body)
+		}
+	})
+}
+
+func TestConcurrentRefreshIssuesOnePair(t *testing.T) {
+	ds := NewMemoryDataSource()
+	auth, _ := newTestAuth(t, ds)
+	pair, err := auth.Issue(Principal{Subject: testAdmin, Role: RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh := `{"refresh_token":"` + pair.RefreshToken + `"}`
+
+	const requests = 20
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serveJSON(auth.RefreshHandler, http.MethodPost, "/auth/refresh", refresh).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	issued := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			issued++
+		case http.StatusUnauthorized:
+		default:
+			t.Fatalf("status = %d", code)
+		}
+	}
+	if issued != 1 {
+		t.Fatalf("%d requests got a new pair, want 1", issued)
+	}
+}
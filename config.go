@@ -0,0 +1,581 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// minSigningKeyLength минимальная длина секрета HS256: не короче выхода SHA-256
+const minSigningKeyLength = 32
+
+// envPrefix префикс переменных окружения: флаг -db-host читается из APP_DB_HOST
+const envPrefix = "APP_"
+
+// Config настройки сервера. Источники в порядке возрастания приоритета:
+// значения по умолчанию, файл (-config, YAML или JSON), переменные окружения APP_*, флаги
+type Config struct {
+	ListenAddr string         `json:"listen_addr" yaml:"listen_addr"`
+	DB         DBConfig       `json:"db" yaml:"db"`
+	HTTP       HTTPConfig     `json:"http" yaml:"http"`
+	Health     HealthConfig   `json:"health" yaml:"health"`
+	Auth       AuthConfig     `json:"auth" yaml:"auth"`
+	Accounts   AccountsConfig `json:"accounts" yaml:"accounts"`
+	Mail       MailConfig     `json:"mail" yaml:"mail"`
+	LogLevel   string         `json:"log_level" yaml:"log_level"`
+	// LogFormat формат логов: text или json
+	LogFormat string `json:"log_format" yaml:"log_format"`
+	// Seed заполнение демонстрационными данными: off, always или if-empty
+	Seed string `json:"seed" yaml:"seed"`
+}
+
+// DBConfig подключение к хранилищу. Если DSN задан, он используется как есть,
+// иначе строка подключения к PostgreSQL собирается из Host, Port, User, Password, Name и SSLMode
+type DBConfig struct {
+	DSN      string `json:"dsn" yaml:"dsn"`
+	Host     string `json:"host" yaml:"host"`
+	Port     int    `json:"port" yaml:"port"`
+	User     string `json:"user" yaml:"user"`
+	Password string `json:"password" yaml:"password"`
+	Name     string `json:"name" yaml:"name"`
+	SSLMode  string `json:"sslmode" yaml:"sslmode"`
+
+	MaxOpenConns    int      `json:"max_open_conns" yaml:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns" yaml:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime" yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time" yaml:"conn_max_idle_time"`
+
+	Timeouts QueryTimeouts `json:"timeouts" yaml:"timeouts"`
+}
+
+// QueryTimeouts ограничения времени операций с хранилищем. Операция прерывается и раньше,
+// если клиент закрыл соединение
+type QueryTimeouts struct {
+	// Read чтение одной записи
+	Read Duration `json:"read" yaml:"read"`
+	// List страница списка, студенты курса, курсы студента
+	List Duration `json:"list" yaml:"list"`
+	// Write создание, изменение и удаление
+	Write Duration `json:"write" yaml:"write"`
+}
+
+// HTTPConfig таймауты и ограничения HTTP-сервера.
+// ShutdownTimeout — сколько ждать завершения текущих запросов после SIGINT/SIGTERM
+type HTTPConfig struct {
+	ReadTimeout     Duration `json:"read_timeout" yaml:"read_timeout"`
+	WriteTimeout    Duration `json:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout     Duration `json:"idle_timeout" yaml:"idle_timeout"`
+	ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	// MaxBodyBytes максимальный размер тела запроса; на больший сервер отвечает 413
+	MaxBodyBytes int `json:"max_body_bytes" yaml:"max_body_bytes"`
+}
+
+// HealthConfig проверки готовности
+type HealthConfig struct {
+	// Timeout ограничивает проверку зависимостей в /health/ready
+	Timeout Duration `json:"timeout" yaml:"timeout"`
+}
+
+// AuthConfig выпуск токенов доступа
+type AuthConfig struct {
+	// SigningKeys ключи HMAC; первым подписываются новые токены, остальные принимаются
+	// при проверке, пока не истекут выданные ими токены
+	SigningKeys SigningKeys `json:"signing_keys" yaml:"signing_keys"`
+	Issuer      string      `json:"issuer" yaml:"issuer"`
+	AccessTTL   Duration    `json:"access_ttl" yaml:"access_ttl"`
+	RefreshTTL  Duration    `json:"refresh_ttl" yaml:"refresh_ttl"`
+	// AdminUser и AdminPassword администратор, создаваемый при первом запуске, если его еще нет
+	AdminUser     string `json:"admin_user" yaml:"admin_user"`
+	AdminPassword string `json:"admin_password" yaml:"admin_password"`
+}
+
+// AccountsConfig учетные записи пользователей
+type AccountsConfig struct {
+	// BaseURL адрес, с которого начинаются ссылки в письмах приглашений и сброса пароля
+	BaseURL           string   `json:"base_url" yaml:"base_url"`
+	InviteTTL         Duration `json:"invite_ttl" yaml:"invite_ttl"`
+	ResetTTL          Duration `json:"reset_ttl" yaml:"reset_ttl"`
+	MinPasswordLength int      `json:"min_password_length" yaml:"min_password_length"`
+	// MaxFailedLogins неудачных входов подряд блокируют учетную запись на LockoutDuration
+	MaxFailedLogins int      `json:"max_failed_logins" yaml:"max_failed_logins"`
+	LockoutDuration Duration `json:"lockout_duration" yaml:"lockout_duration"`
+}
+
+// MailConfig отправка писем. Пока письма сохраняются файлами в Dir
+type MailConfig struct {
+	Dir  string `json:"dir" yaml:"dir"`
+	From string `json:"from" yaml:"from"`
+}
+
+// SigningKey ключ подписи токенов; ID записывается в заголовок kid
+type SigningKey struct {
+	ID     string `json:"id" yaml:"id"`
+	Secret string `json:"secret" yaml:"secret"`
+}
+
+// SigningKeys в окружении и флагах записываются как "id1:secret1,id2:secret2"
+type SigningKeys []SigningKey
+
+func (k *SigningKeys) set(value string) error {
+	var keys SigningKeys
+	for i, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		// Значение не выводится в ошибке: это секрет
+		id, secret, ok := strings.Cut(item, ":")
+		if !ok {
+			return fmt.Errorf("signing key #%d: expected id:secret", i+1)
+		}
+		keys = append(keys, SigningKey{ID: id, Secret: secret})
+	}
+	*k = keys
+	return nil
+}
+
+// Duration time.Duration, который в файле, окружении и флагах записывается как "5s" или "1m30s"
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// CommandLine аргументы командной строки, не относящиеся к Config
+type CommandLine struct {
+	ConfigFile  string
+	PrintConfig bool
+	// Args позиционные аргументы, например `migrate up`
+	Args []string
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		ListenAddr: ":8081",
+		DB: DBConfig{
+			Host:            "localhost",
+			Port:            5432,
+			User:            "user",
+			Name:            "postgres",
+			SSLMode:         "disable",
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration{30 * time.Minute},
+			ConnMaxIdleTime: Duration{5 * time.Minute},
+			Timeouts: QueryTimeouts{
+				Read:  Duration{2 * time.Second},
+				List:  Duration{5 * time.Second},
+				Write: Duration{5 * time.Second},
+			},
+		},
+		HTTP: HTTPConfig{
+			ReadTimeout:     Duration{10 * time.Second},
+			WriteTimeout:    Duration{30 * time.Second},
+			IdleTimeout:     Duration{2 * time.Minute},
+			ShutdownTimeout: Duration{20 * time.Second},
+			MaxBodyBytes:    1 << 20,
+		},
+		Health: HealthConfig{
+			Timeout: Duration{2 * time.Second},
+		},
+		Auth: AuthConfig{
+			Issuer:     "school-api",
+			AccessTTL:  Duration{15 * time.Minute},
+			RefreshTTL: Duration{7 * 24 * time.Hour},
+			AdminUser:  "admin",
+		},
+		Accounts: AccountsConfig{
+			BaseURL:           "http://localhost:8081",
+			InviteTTL:         Duration{72 * time.Hour},
+			ResetTTL:          Duration{time.Hour},
+			MinPasswordLength: 8,
+			MaxFailedLogins:   5,
+			LockoutDuration:   Duration{15 * time.Minute},
+		},
+		Mail: MailConfig{
+			Dir:  "mail",
+			From: "noreply@localhost",
+		},
+		LogLevel:  "info",
+		LogFormat: "text",
+		Seed:      "if-empty",
+	}
+}
+
+// setting одна настройка, доступная через флаг и переменную окружения
+type setting struct {
+	flag  string
+	usage string
+	value interface{}
+}
+
+// settings перечисляет настройки Config; имя переменной окружения выводится из имени флага
+func (c *Config) settings() []setting {
+	return []setting{
+		{"listen", "адрес HTTP-сервера", &c.ListenAddr},
+		{"dsn", "строка подключения: postgres://..., sqlite://файл.db или memory://; имеет приоритет над -db-*", &c.DB.DSN},
+		{"db-host", "хост PostgreSQL", &c.DB.Host},
+		{"db-port", "порт PostgreSQL", &c.DB.Port},
+		{"db-user", "пользователь PostgreSQL", &c.DB.User},
+		{"db-password", "пароль PostgreSQL", &c.DB.Password},
+		{"db-name", "имя базы данных", &c.DB.Name},
+		{"db-sslmode", "режим SSL PostgreSQL", &c.DB.SSLMode},
+		{"db-max-open-conns", "максимум открытых соединений (0 — без ограничения)", &c.DB.MaxOpenConns},
+		{"db-max-idle-conns", "максимум простаивающих соединений", &c.DB.MaxIdleConns},
+		{"db-conn-max-lifetime", "максимальное время жизни соединения", &c.DB.ConnMaxLifetime},
+		{"db-conn-max-idle-time", "максимальное время простоя соединения", &c.DB.ConnMaxIdleTime},
+		{"db-read-timeout", "таймаут чтения одной записи", &c.DB.Timeouts.Read},
+		{"db-list-timeout", "таймаут запроса списка", &c.DB.Timeouts.List},
+		{"db-write-timeout", "таймаут изменения данных", &c.DB.Timeouts.Write},
+		{"http-read-timeout", "таймаут чтения запроса", &c.HTTP.ReadTimeout},
+		{"http-write-timeout", "таймаут записи ответа", &c.HTTP.WriteTimeout},
+		{"http-idle-timeout", "таймаут простоя keep-alive соединения", &c.HTTP.IdleTimeout},
+		{"http-shutdown-timeout", "время на завершение текущих запросов при остановке", &c.HTTP.ShutdownTimeout},
+		{"http-max-body-bytes", "максимальный размер тела запроса в байтах", &c.HTTP.MaxBodyBytes},
+		{"health-timeout", "таймаут проверок зависимостей в /health/ready", &c.Health.Timeout},
+		{"auth-signing-keys", "ключи подписи токенов: id:secret[,id:secret...], первый подписывает", &c.Auth.SigningKeys},
+		{"auth-issuer", "издатель токенов (iss)", &c.Auth.Issuer},
+		{"auth-access-ttl", "срок действия access-токена", &c.Auth.AccessTTL},
+		{"auth-refresh-ttl", "срок действия refresh-токена", &c.Auth.RefreshTTL},
+		{"auth-admin-user", "имя администратора", &c.Auth.AdminUser},
+		{"auth-admin-password", "пароль администратора, создаваемого при первом запуске", &c.Auth.AdminPassword},
+		{"accounts-base-url", "адрес для ссылок в письмах", &c.Accounts.BaseURL},
+		{"accounts-invite-ttl", "срок действия приглашения", &c.Accounts.InviteTTL},
+		{"accounts-reset-ttl", "срок действия ссылки сброса пароля", &c.Accounts.ResetTTL},
+		{"accounts-min-password-length", "минимальная длина пароля", &c.Accounts.MinPasswordLength},
+		{"accounts-max-failed-logins", "неудачных входов до блокировки", &c.Accounts.MaxFailedLogins},
+		{"accounts-lockout-duration", "время блокировки входа", &c.Accounts.LockoutDuration},
+		{"mail-dir", "каталог, в который сохраняются письма", &c.Mail.Dir},
+		{"mail-from", "адрес отправителя писем", &c.Mail.From},
+		{"log-level", "уровень логирования: debug, info, warn, error", &c.LogLevel},
+		{"log-format", "формат логов: text или json", &c.LogFormat},
+		{"seed", "демонстрационные данные: off, always, if-empty", &c.Seed},
+	}
+}
+
+// envName имя переменной окружения для флага: db-host -> APP_DB_HOST
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// LoadConfig собирает Config из значений по умолчанию, файла, окружения и флагов и проверяет его.
+// Ошибки всех источников и проверки возвращаются вместе
+func LoadConfig(args []string, lookupEnv func(string) (string, bool)) (Config, CommandLine, error) {
+	cfg := DefaultConfig()
+	var cmd CommandLine
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&cmd.ConfigFile, "config", "", "файл настроек (.yaml, .yml или .json); также "+envName("config"))
+	fs.BoolVar(&cmd.PrintConfig, "print-config", false, "вывести итоговые настройки со скрытыми секретами и выйти")
+
+	// Флаги запоминаются и применяются последними, после файла и окружения
+	flagValues := make(map[string]string)
+	for _, s := range cfg.settings() {
+		name := s.flag
+		fs.Func(name, s.usage+" ("+envName(name)+")", func(v string) error {
+			flagValues[name] = v
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, cmd, err
+	}
+	cmd.Args = fs.Args()
+
+	if cmd.ConfigFile == "" {
+		cmd.ConfigFile, _ = lookupEnv(envName("config"))
+	}
+	if cmd.ConfigFile != "" {
+		if err := cfg.loadFile(cmd.ConfigFile); err != nil {
+			return Config{}, cmd, err
+		}
+	}
+
+	var errs []error
+	for _, s := range cfg.settings() {
+		if v, ok := lookupEnv(envName(s.flag)); ok {
+			if err := setValue(s.value, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", envName(s.flag), err))
+			}
+		}
+	}
+	for _, s := range cfg.settings() {
+		if v, ok := flagValues[s.flag]; ok {
+			if err := setValue(s.value, v); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.flag, err))
+			}
+		}
+	}
+	errs = append(errs, cfg.validate()...)
+	return cfg, cmd, errors.Join(errs...)
+}
+
+// loadFile читает настройки из YAML или JSON; неизвестные ключи считаются ошибкой
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(c)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(c)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .json", path)
+	}
+	// Пустой файл допустим и ничего не меняет
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// setValue записывает строковое значение из окружения или флага в поле Config
+func setValue(target interface{}, value string) error {
+	switch p := target.(type) {
+	case *string:
+		*p = value
+	case *int:
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*p = v
+	case *Duration:
+		if err := p.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+	case *SigningKeys:
+		return p.set(value)
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+	return nil
+}
+
+// validate проверяет настройки и возвращает все найденные ошибки
+func (c Config) validate() []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, port, err := net.SplitHostPort(c.ListenAddr); err != nil {
+		fail("listen_addr %q: expected host:port", c.ListenAddr)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		fail("listen_addr %q: invalid port", c.ListenAddr)
+	}
+
+	if c.DB.DSN != "" {
+		u, err := url.Parse(c.DB.DSN)
+		switch {
+		case err != nil:
+			fail("db.dsn: %v", err)
+		case u.Scheme != "postgres" && u.Scheme != "postgresql" && u.Scheme != "sqlite" && u.Scheme != "memory":
+			fail("db.dsn: unsupported scheme %q, use postgres://, sqlite:// or memory://", u.Scheme)
+		}
+	} else {
+		if c.DB.Host == "" {
+			fail("db.host is required when db.dsn is not set")
+		}
+		if c.DB.Port <= 0 || c.DB.Port > 65535 {
+			fail("db.port %d: must be between 1 and 65535", c.DB.Port)
+		}
+		if c.DB.User == "" {
+			fail("db.user is required when db.dsn is not set")
+		}
+		if c.DB.Name == "" {
+			fail("db.name is required when db.dsn is not set")
+		}
+	}
+
+	if c.DB.MaxOpenConns < 0 {
+		fail("db.max_open_conns must not be negative")
+	}
+	if c.DB.MaxIdleConns < 0 {
+		fail("db.max_idle_conns must not be negative")
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		fail("db.max_idle_conns (%d) must not exceed db.max_open_conns (%d)", c.DB.MaxIdleConns, c.DB.MaxOpenConns)
+	}
+	if c.DB.ConnMaxLifetime.Duration < 0 || c.DB.ConnMaxIdleTime.Duration < 0 {
+		fail("db connection lifetimes must not be negative")
+	}
+
+	for name, d := range map[string]Duration{
+		"db.timeouts.read":          c.DB.Timeouts.Read,
+		"db.timeouts.list":          c.DB.Timeouts.List,
+		"db.timeouts.write":         c.DB.Timeouts.Write,
+		"http.read_timeout":         c.HTTP.ReadTimeout,
+		"http.write_timeout":        c.HTTP.WriteTimeout,
+		"http.idle_timeout":         c.HTTP.IdleTimeout,
+		"http.shutdown_timeout":     c.HTTP.ShutdownTimeout,
+		"health.timeout":            c.Health.Timeout,
+		"auth.access_ttl":           c.Auth.AccessTTL,
+		"auth.refresh_ttl":          c.Auth.RefreshTTL,
+		"accounts.invite_ttl":       c.Accounts.InviteTTL,
+		"accounts.reset_ttl":        c.Accounts.ResetTTL,
+		"accounts.lockout_duration": c.Accounts.LockoutDuration,
+	} {
+		if d.Duration <= 0 {
+			fail("%s must be positive", name)
+		}
+	}
+
+	if c.HTTP.MaxBodyBytes <= 0 {
+		fail("http.max_body_bytes must be positive")
+	}
+
+	if c.Auth.RefreshTTL.Duration < c.Auth.AccessTTL.Duration {
+		fail("auth.refresh_ttl must not be shorter than auth.access_ttl")
+	}
+	if c.Auth.Issuer == "" {
+		fail("auth.issuer is required")
+	}
+	keyIDs := make(map[string]bool)
+	for i, key := range c.Auth.SigningKeys {
+		switch {
+		case key.ID == "":
+			fail("auth.signing_keys[%d]: id is required", i)
+		case keyIDs[key.ID]:
+			fail("auth.signing_keys[%d]: duplicate id %q", i, key.ID)
+		}
+		keyIDs[key.ID] = true
+		if len(key.Secret) < minSigningKeyLength {
+			fail("auth.signing_keys[%d]: secret must be at least %d bytes", i, minSigningKeyLength)
+		}
+	}
+	if c.Auth.AdminPassword != "" && c.Auth.AdminUser == "" {
+		fail("auth.admin_user is required when auth.admin_password is set")
+	}
+	if c.Accounts.MinPasswordLength < 8 {
+		fail("accounts.min_password_length must be at least 8")
+	}
+	if c.Auth.AdminPassword != "" && len([]rune(c.Auth.AdminPassword)) < c.Accounts.MinPasswordLength {
+		fail("auth.admin_password must be at least %d characters", c.Accounts.MinPasswordLength)
+	}
+	if c.Accounts.MaxFailedLogins <= 0 {
+		fail("accounts.max_failed_logins must be positive")
+	}
+	if u, err := url.Parse(c.Accounts.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		fail("accounts.base_url %q: expected an absolute URL", c.Accounts.BaseURL)
+	}
+	if c.Mail.Dir == "" {
+		fail("mail.dir is required")
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		fail("log_level %q: use debug, info, warn or error", c.LogLevel)
+	}
+	switch c.LogFormat {
+	case "text", "json":
+	default:
+		fail("log_format %q: use text or json", c.LogFormat)
+	}
+	switch c.Seed {
+	case "off", "always", "if-empty":
+	default:
+		fail("seed %q: use off, always or if-empty", c.Seed)
+	}
+	return errs
+}
+
+// URL возвращает строку подключения: DSN, если он задан, иначе собранную из частей
+func (c DBConfig) URL() string {
+	if c.DSN != "" {
+		return c.DSN
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:     "/" + c.Name,
+		RawQuery: url.Values{"sslmode": {c.SSLMode}}.Encode(),
+	}
+	if c.Password == "" {
+		u.User = url.User(c.User)
+	}
+	return u.String()
+}
+
+// Redacted возвращает копию настроек со скрытыми паролями для вывода и логов
+func (c Config) Redacted() Config {
+	if c.DB.Password != "" {
+		c.DB.Password = "xxxxx"
+	}
+	if c.Auth.AdminPassword != "" {
+		c.Auth.AdminPassword = "xxxxx"
+	}
+	keys := make(SigningKeys, len(c.Auth.SigningKeys))
+	for i, key := range c.Auth.SigningKeys {
+		keys[i] = SigningKey{ID: key.ID, Secret: "xxxxx"}
+	}
+	c.Auth.SigningKeys = keys
+	c.DB.DSN = redactDSN(c.DB.DSN)
+	return c
+}
+
+// dsnPassword пароль в DSN вида "host=db password='secret' dbname=school"
+var dsnPassword = regexp.MustCompile(`(?i)(\bpassword\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN скрывает пароль в строке подключения: в URL — в userinfo и параметре password,
+// в формате ключ=значение — в ключе password. Строки без пароля, например memory://,
+// возвращаются как есть
+func redactDSN(dsn string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsnPassword.ReplaceAllString(dsn, "${1}xxxxx")
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "xxxxx"
+	}
+	changed := false
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+		changed = true
+	}
+	query := u.Query()
+	for key := range query {
+		if strings.EqualFold(key, "password") {
+			query[key] = []string{"xxxxx"}
+			changed = true
+		}
+	}
+	if !changed {
+		return dsn
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
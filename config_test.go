@@ -0,0 +1,200 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mapEnv подменяет os.LookupEnv в LoadConfig
+func mapEnv(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+// writeConfigFile сохраняет файл настроек во временный каталог теста
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	yamlFile := writeConfigFile(t, "config.yaml", `
+listen_addr: ":9000"
+log_level: debug
+db:
+  host: file-host
+  port: 6432
+  timeouts:
+    read: 3s
+`)
+	jsonFile := writeConfigFile(t, "config.json", `{"listen_addr": ":9001", "db": {"host": "json-host"}}`)
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want func(c *Config)
+	}{
+		{"defaults", nil, nil, func(c *Config) {}},
+		{"yaml file", []string{"-config", yamlFile}, nil, func(c *Config) {
+			c.ListenAddr = ":9000"
+			c.LogLevel = "debug"
+			c.DB.Host = "file-host"
+			c.DB.Port = 6432
+			c.DB.Timeouts.Read = Duration{3 * time.Second}
+		}},
+		{"json file from env", nil, map[string]string{"APP_CONFIG": jsonFile}, func(c *Config) {
+			c.ListenAddr = ":9001"
+			c.DB.Host = "json-host"
+		}},
+		{"env over file", []string{"-config", yamlFile}, map[string]string{
+			"APP_DB_HOST":         "env-host",
+			"APP_DB_READ_TIMEOUT": "4s",
+		}, func(c *Config) {
+			c.ListenAddr = ":9000"
+			c.LogLevel = "debug"
+			c.DB.Host = "env-host"
+			c.DB.Port = 6432
+			c.DB.Timeouts.Read = Duration{4 * time.Second}
+		}},
+		{"flags over env and file", []string{"-config", yamlFile, "-db-host", "flag-host", "-log-level", "warn"},
+			map[string]string{"APP_DB_HOST": "env-host", "APP_LOG_LEVEL": "error"}, func(c *Config) {
+				c.ListenAddr = ":9000"
+				c.LogLevel = "warn"
+				c.DB.Host = "flag-host"
+				c.DB.Port = 6432
+				c.DB.Timeouts.Read = Duration{3 * time.Second}
+			}},
+		{"signing keys", []string{"-auth-signing-keys", "new:" + strings.Repeat("n", 32) + ", old:" + strings.Repeat("o", 32)}, nil,
+			func(c *Config) {
+				c.Auth.SigningKeys = SigningKeys{{"new", strings.Repeat("n", 32)}, {"old", strings.Repeat("o", 32)}}
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := LoadConfig(tt.args, mapEnv(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := DefaultConfig()
+			tt.want(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("config = %+v\nwant %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigCommandLine(t *testing.T) {
+	_, cmd, err := LoadConfig([]string{"-print-config", "migrate", "down", "2"}, mapEnv(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cmd.PrintConfig || !reflect.DeepEqual(cmd.Args, []string{"migrate", "down", "2"}) {
+		t.Fatalf("command line = %+v", cmd)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tomlFile := writeConfigFile(t, "config.toml", "listen_addr = \":9000\"\n")
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		// file содержимое config.yaml; пустая строка — без файла
+		file     string
+		wantErrs []string
+	}{
+		{"listen without port", []string{"-listen", "8080"}, nil, "", []string{"listen_addr"}},
+		{"bad integer flag", []string{"-db-port", "abc"}, nil, "", []string{`-db-port: invalid integer "abc"`}},
+		{"bad duration env", nil, map[string]string{"APP_HTTP_READ_TIMEOUT": "soon"}, "",
+			[]string{`APP_HTTP_READ_TIMEOUT: invalid duration "soon"`}},
+		{"port out of range", []string{"-db-port", "70000"}, nil, "", []string{"db.port 70000"}},
+		{"unsupported dsn", []string{"-dsn", "mysql://localhost/school"}, nil, "", []string{`unsupported scheme "mysql"`}},
+		{"idle over open", []string{"-db-max-open-conns", "2", "-db-max-idle-conns", "5"}, nil, "",
+			[]string{"db.max_idle_conns (5) must not exceed db.max_open_conns (2)"}},
+		{"zero timeout", []string{"-db-write-timeout", "0s"}, nil, "", []string{"db.timeouts.write must be positive"}},
+		{"refresh shorter than access", []string{"-auth-access-ttl", "2h", "-auth-refresh-ttl", "1h"}, nil, "",
+			[]string{"auth.refresh_ttl must not be shorter"}},
+		{"short signing key", []string{"-auth-signing-keys", "k1:short"}, nil, "", []string{"auth.signing_keys[0]: secret must be at least 32 bytes"}},
+		{"duplicate signing key", []string{"-auth-signing-keys", "k1:" + strings.Repeat("a", 32) + ",k1:" + strings.Repeat("b", 32)}, nil, "",
+			[]string{`auth.signing_keys[1]: duplicate id "k1"`}},
+		{"short admin password", []string{"-auth-admin-password", "short"}, nil, "", []string{"auth.admin_password must be at least 8"}},
+		{"relative base url", []string{"-accounts-base-url", "/school"}, nil, "", []string{"accounts.base_url"}},
+		{"all errors together", []string{"-log-level", "verbose", "-log-format", "xml", "-seed", "never"}, nil, "",
+			[]string{`log_level "verbose"`, `log_format "xml"`, `seed "never"`}},
+		{"unknown file key", nil, nil, "listen: \":9000\"\n", []string{"field listen not found"}},
+		{"missing file", []string{"-config", "missing.yaml"}, nil, "", []string{"config file"}},
+		{"unsupported file format", []string{"-config", tomlFile}, nil, "", []string{"unsupported format"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeConfigFile(t, "config.yaml", tt.file)}, args...)
+			}
+			_, _, err := LoadConfig(args, mapEnv(tt.env))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want string
+	}{
+		{"", ""},
+		{"memory://", "memory://"},
+		{"sqlite://school.db", "sqlite://school.db"},
+		{"sqlite:///var/lib/school.db?_pragma=journal_mode(WAL)", "sqlite:///var/lib/school.db?_pragma=journal_mode(WAL)"},
+		{"postgres://user@db:5432/school?sslmode=disable", "postgres://user@db:5432/school?sslmode=disable"},
+		{"postgres://user:secret@db:5432/school", "postgres://user:xxxxx@db:5432/school"},
+		{"postgres://user@db/school?password=secret&sslmode=disable", "postgres://user@db/school?password=xxxxx&sslmode=disable"},
+		{"host=db user=user password=secret dbname=school", "host=db user=user password=xxxxx dbname=school"},
+		{"host=db PASSWORD = 'a b\\'c' dbname=school", "host=db PASSWORD = xxxxx dbname=school"},
+	}
+	for _, tt := range tests {
+		if got := redactDSN(tt.dsn); got != tt.want {
+			t.Errorf("redactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DB.DSN = "postgres://user:secret@db/school"
+	cfg.DB.Password = "secret"
+	cfg.Auth.AdminPassword = "admin-secret"
+	cfg.Auth.SigningKeys = SigningKeys{{"k1", strings.Repeat("s", 32)}}
+
+	got := cfg.Redacted()
+	if got.DB.Password != "xxxxx" || got.Auth.AdminPassword != "xxxxx" || got.Auth.SigningKeys[0] != (SigningKey{"k1", "xxxxx"}) {
+		t.Fatalf("secrets are not redacted: %+v", got)
+	}
+	if got.DB.DSN != "postgres://user:xxxxx@db/school" {
+		t.Fatalf("dsn = %q", got.DB.DSN)
+	}
+	// Исходные настройки не меняются
+	if cfg.Auth.SigningKeys[0].Secret != strings.Repeat("s", 32) || cfg.DB.DSN != "postgres://user:secret@db/school" {
+		t.Fatalf("Redacted changed the original config: %+v", cfg)
+	}
+}
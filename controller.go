@@ -1,13 +1,38 @@
 package main
 
+import (
+	"log/slog"
+	"net/http"
+)
+
 type Controller struct {
-	service *Service
+	service  *Service
+	accounts *Accounts
+	policy   Policy
+	logger   *slog.Logger
 	// teacherService
 	// student service
 }
 
-func NewController(service *Service) *Controller {
+func NewController(service *Service, accounts *Accounts, policy Policy, logger *slog.Logger) *Controller {
 	return &Controller{
-		service: service,
+		service:  service,
+		accounts: accounts,
+		policy:   policy,
+		logger:   logger,
+	}
+}
+
+// authorize проверяет, может ли пользователь из токена запроса выполнить action над resource.
+// Отказы пишутся в лог
+func (c *Controller) authorize(r *http.Request, action Action, resource Resource) error {
+	claims, _ := claimsFrom(r.Context())
+	principal := claims.Principal()
+	if err := c.policy.Authorize(principal, action, resource); err != nil {
+		c.logger.WarnContext(r.Context(), "access denied",
+			"subject", principal.Subject, "role", principal.Role, "action", string(action),
+			"teacher_id", resource.TeacherID, "student_id", resource.StudentID)
+		return err
 	}
+	return nil
 }
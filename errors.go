@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrorKind категория доменной ошибки, по которой выбирается HTTP-статус
+type ErrorKind string
+
+const (
+	KindNotFound             ErrorKind = "not_found"
+	KindConflict             ErrorKind = "conflict"
+	KindValidation           ErrorKind = "validation"
+	KindUnprocessable        ErrorKind = "unprocessable"
+	KindTooLarge             ErrorKind = "too_large"
+	KindPrecondition         ErrorKind = "precondition_failed"
+	KindPreconditionRequired ErrorKind = "precondition_required"
+	KindUnauthorized         ErrorKind = "unauthorized"
+	KindForbidden            ErrorKind = "forbidden"
+	KindUnavailable          ErrorKind = "unavailable"
+	KindTimeout              ErrorKind = "timeout"
+	KindCanceled             ErrorKind = "canceled"
+	KindInternal             ErrorKind = "internal"
+)
+
+// Error доменная ошибка Service.
+// Code — стабильный машиночитаемый код, Field — поле запроса, к которому относится ошибка,
+// Fields — ошибки отдельных полей тела запроса (KindUnprocessable)
+type Error struct {
+	Kind    ErrorKind
+	Code    string
+	Message string
+	Field   string
+	Fields  []FieldError
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is считает ошибки с одинаковым кодом равными, чтобы errors.Is(err, ErrEmailTaken)
+// срабатывал и для копий с заполненным сообщением
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+var (
+	ErrTeacherNotFound    = &Error{Kind: KindNotFound, Code: "teacher_not_found", Message: "teacher not found"}
+	ErrStudentNotFound    = &Error{Kind: KindNotFound, Code: "student_not_found", Message: "student not found"}
+	ErrCourseNotFound     = &Error{Kind: KindNotFound, Code: "course_not_found", Message: "course not found"}
+	ErrEnrollmentNotFound = &Error{Kind: KindNotFound, Code: "enrollment_not_found", Message: "enrollment not found"}
+	ErrUserNotFound       = &Error{Kind: KindNotFound, Code: "user_not_found", Message: "user not found"}
+
+	// ErrTeacherHasCourses возвращается при удалении преподавателя, за которым закреплены курсы
+	ErrTeacherHasCourses = &Error{Kind: KindConflict, Code: "teacher_has_courses", Message: "teacher has courses"}
+	ErrAlreadyEnrolled   = &Error{Kind: KindConflict, Code: "already_enrolled", Message: "student already enrolled in course"}
+	ErrEmailTaken        = &Error{Kind: KindConflict, Code: "email_taken", Field: "email", Message: "email already exists"}
+	// ErrUserExists учетная запись с таким именем или для этой записи уже создана
+	ErrUserExists = &Error{Kind: KindConflict, Code: "user_exists", Message: "user already exists"}
+
+	ErrInvalidJSON  = &Error{Kind: KindValidation, Code: "invalid_json", Message: "Неверный формат JSON"}
+	ErrEmptyBody    = &Error{Kind: KindValidation, Code: "empty_body", Message: "request body is empty"}
+	ErrInvalidID    = &Error{Kind: KindValidation, Code: "invalid_id", Field: "id", Message: "Неверный ID"}
+	ErrWeakPassword = &Error{Kind: KindValidation, Code: "weak_password", Field: "password", Message: "password is too weak"}
+	// ErrInvalidUserToken токен приглашения или сброса пароля не найден, истек или уже использован
+	ErrInvalidUserToken = &Error{Kind: KindValidation, Code: "invalid_account_token", Field: "token", Message: "token is invalid or expired"}
+
+	ErrUnauthorized       = &Error{Kind: KindUnauthorized, Code: "unauthorized", Message: "bearer token required"}
+	ErrInvalidCredentials = &Error{Kind: KindUnauthorized, Code: "invalid_credentials", Message: "invalid username or password"}
+	ErrInvalidToken       = &Error{Kind: KindUnauthorized, Code: "invalid_token", Message: "invalid token"}
+	ErrTokenExpired       = &Error{Kind: KindUnauthorized, Code: "token_expired", Message: "token expired"}
+	ErrTokenRevoked       = &Error{Kind: KindUnauthorized, Code: "token_revoked", Message: "token revoked"}
+	ErrAccountLocked      = &Error{Kind: KindUnauthorized, Code: "account_locked", Message: "account is temporarily locked after repeated login failures"}
+	ErrForbidden          = &Error{Kind: KindForbidden, Code: "forbidden", Message: "access denied"}
+
+	// ErrInvalidFields тело запроса разобрано, но значения полей недопустимы; подробности в Fields
+	ErrInvalidFields = &Error{Kind: KindUnprocessable, Code: "invalid_fields", Message: "request has invalid fields"}
+	// ErrUnknownTeacher курс ссылается на несуществующего преподавателя
+	ErrUnknownTeacher = &Error{Kind: KindUnprocessable, Code: "unknown_teacher", Field: "teacher_id", Message: "teacher_id refers to a missing teacher"}
+	// ErrUnknownStudent в списке студентов для записи на курс есть несуществующий
+	ErrUnknownStudent = &Error{Kind: KindUnprocessable, Code: "unknown_student", Field: "student_ids", Message: "student_ids refers to a missing student"}
+	ErrBodyTooLarge   = &Error{Kind: KindTooLarge, Code: "body_too_large", Message: "request body is too large"}
+
+	// ErrVersionMismatch запись изменилась после того, как клиент получил ее ETag
+	ErrVersionMismatch      = &Error{Kind: KindPrecondition, Code: "version_mismatch", Message: "record was modified by another request, reload it and retry"}
+	ErrPreconditionRequired = &Error{Kind: KindPreconditionRequired, Code: "precondition_required", Message: "If-Match header is required"}
+	ErrInvalidIfMatch       = &Error{Kind: KindValidation, Code: "invalid_if_match", Message: "If-Match must contain a single ETag or *"}
+
+	// ErrStorageTimeout операция с хранилищем не уложилась в таймаут db.timeouts
+	ErrStorageTimeout = &Error{Kind: KindTimeout, Code: "storage_timeout", Message: "storage operation timed out"}
+	// ErrRequestCanceled клиент закрыл соединение, не дождавшись ответа
+	ErrRequestCanceled = &Error{Kind: KindCanceled, Code: "request_canceled", Message: "request canceled by client"}
+)
+
+// statusClientClosedRequest нестандартный статус nginx для запроса, прерванного клиентом.
+// Клиент его уже не получит, но он попадает в access-лог и метрики
+const statusClientClosedRequest = 499
+
+// emailTaken возвращает ErrEmailTaken с указанием повторяющегося адреса
+func emailTaken(email string) error {
+	return &Error{
+		Kind:    KindConflict,
+		Code:    ErrEmailTaken.Code,
+		Field:   ErrEmailTaken.Field,
+		Message: fmt.Sprintf("email %q already exists", email),
+	}
+}
+
+// weakPassword возвращает ErrWeakPassword с описанием нарушенного требования
+func weakPassword(message string) error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    ErrWeakPassword.Code,
+		Field:   ErrWeakPassword.Field,
+		Message: message,
+	}
+}
+
+// invalidFields возвращает ErrInvalidFields с ошибками полей
+func invalidFields(fields ...FieldError) error {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Field
+	}
+	return &Error{
+		Kind:    KindUnprocessable,
+		Code:    ErrInvalidFields.Code,
+		Message: fmt.Sprintf("%s: %s", ErrInvalidFields.Message, strings.Join(names, ", ")),
+		Fields:  fields,
+	}
+}
+
+// invalidJSON возвращает ErrInvalidJSON с описанием ошибки разбора
+func invalidJSON(detail string) error {
+	return &Error{
+		Kind:    KindValidation,
+		Code:    ErrInvalidJSON.Code,
+		Message: fmt.Sprintf("%s: %s", ErrInvalidJSON.Message, detail),
+	}
+}
+
+// domainError приводит ошибку хранилища к *Error: истекший контекст становится KindTimeout,
+// отмененный — KindCanceled, сбои соединения — KindUnavailable, все остальное — KindInternal
+func domainError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var domainErr *Error
+	switch {
+	case errors.As(err, &domainErr):
+		return err
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Error{Kind: KindTimeout, Code: ErrStorageTimeout.Code, Message: ErrStorageTimeout.Message, Err: err}
+	case errors.Is(err, context.Canceled):
+		return &Error{Kind: KindCanceled, Code: ErrRequestCanceled.Code, Message: ErrRequestCanceled.Message, Err: err}
+	case isUnavailable(err):
+		return &Error{Kind: KindUnavailable, Code: "storage_unavailable", Message: "storage unavailable", Err: err}
+	}
+	return &Error{Kind: KindInternal, Code: "internal", Message: "internal error", Err: err}
+}
+
+// interruptReason метка прерванной операции для логов и метрик: timeout или canceled
+func interruptReason(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "canceled"
+}
+
+// isUnavailable сообщает, вызвана ли ошибка недоступностью хранилища, а не самим запросом
+func isUnavailable(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone), errors.As(err, &netErr):
+		return true
+	}
+	return isPostgresUnavailable(err) || isSQLiteBusy(err)
+}
+
+// httpStatus возвращает HTTP-статус для категории ошибки
+func httpStatus(kind ErrorKind) int {
+	switch kind {
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindConflict:
+		return http.StatusConflict
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case KindPrecondition:
+		return http.StatusPreconditionFailed
+	case KindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case KindUnauthorized:
+		return http.StatusUnauthorized
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindTimeout:
+		return http.StatusGatewayTimeout
+	case KindCanceled:
+		return statusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
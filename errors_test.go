@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRespondWithError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantCode    string
+		wantMessage string
+		wantFields  []string
+	}{
+		{"not found", ErrTeacherNotFound, http.StatusNotFound, "teacher_not_found", "teacher not found", nil},
+		{"wrapped not found", fmt.Errorf("get course: %w", ErrCourseNotFound), http.StatusNotFound, "course_not_found", "course not found", nil},
+		{"conflict with field", emailTaken("anna@example.com"), http.StatusConflict, "email_taken", `email "anna@example.com" already exists`, nil},
+		{"validation", ErrInvalidID, http.StatusBadRequest, "invalid_id", "Неверный ID", nil},
+		{"unprocessable field", ErrUnknownTeacher, http.StatusUnprocessableEntity, "unknown_teacher",
+			"teacher_id refers to a missing teacher", []string{"teacher_id"}},
+		{"too large", ErrBodyTooLarge, http.StatusRequestEntityTooLarge, "body_too_large", "request body is too large", nil},
+		{"precondition", ErrVersionMismatch, http.StatusPreconditionFailed, "version_mismatch", ErrVersionMismatch.Message, nil},
+		{"precondition required", ErrPreconditionRequired, http.StatusPreconditionRequired, "precondition_required", "If-Match header is required", nil},
+		{"forbidden", ErrForbidden, http.StatusForbidden, "forbidden", "access denied", nil},
+		// Подробности внутренних ошибок клиенту не показываются
+		{"unknown error", errors.New("pq: relation \"teachers\" does not exist"), http.StatusInternalServerError, "internal",
+			"Внутренняя ошибка сервера", nil},
+		{"unavailable", fmt.Errorf("query: %w", driver.ErrBadConn), http.StatusServiceUnavailable, "storage_unavailable",
+			"Хранилище данных недоступно", nil},
+		{"timeout", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "storage_timeout",
+			"Хранилище данных не ответило вовремя", nil},
+		{"canceled", context.Canceled, statusClientClosedRequest, "request_canceled", "request canceled by client", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			respondWithError(w, tt.err)
+			var body errorResponse
+			decodeBody(t, w, &body)
+			if w.Code != tt.wantStatus || body.Code != tt.wantCode || body.Error != tt.wantMessage {
+				t.Fatalf("status = %d, body = %+v", w.Code, body)
+			}
+			var fields []string
+			for _, f := range body.Fields {
+				fields = append(fields, f.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Fatalf("fields = %v, want %v", fields, tt.wantFields)
+			}
+			if strings.Contains(w.Body.String(), "relation") {
+				t.Fatalf("response exposes the error: %s", w.Body)
+			}
+		})
+	}
+}
+
+func TestUnauthorizedResponseNamesTheError(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondWithError(w, ErrTokenExpired)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") != `Bearer error="token_expired"` {
+		t.Fatalf("status = %d, WWW-Authenticate = %q", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestDeleteMissingRecordIsNotFound(t *testing.T) {
+	api := newTestAPI(t, NewMemoryDataSource())
+	for target, code := range map[string]string{
+		"/teachers/999": "teacher_not_found",
+		"/students/999": "student_not_found",
+		"/courses/999":  "course_not_found",
+	} {
+		w := api.do(http.MethodDelete, target, api.adminToken, "", "If-Match", "*")
+		if w.Code != http.StatusNotFound || errorCode(t, w) != code {
+			t.Errorf("DELETE %s: status = %d: %s", target, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag сильный ETag записи: ее версия в кавычках
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag добавляет в ответ ETag текущей версии записи
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// ifMatch возвращает ожидаемую версию из заголовка If-Match: 0 для *, ErrPreconditionRequired
+// без заголовка. If-Match сравнивает ETag строго, поэтому слабый ETag не совпадает ни с одной версией
+func ifMatch(r *http.Request) (int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	switch {
+	case value == "":
+		return 0, ErrPreconditionRequired
+	case value == "*":
+		return 0, nil
+	case strings.Contains(value, ","):
+		return 0, ErrInvalidIfMatch
+	case strings.HasPrefix(value, "W/"):
+		return 0, ErrVersionMismatch
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return 0, ErrInvalidIfMatch
+	}
+	version, err := strconv.Atoi(value[1 : len(value)-1])
+	if err != nil || version <= 0 {
+		// Корректный, но чужой ETag: условие просто не выполняется
+		return 0, ErrVersionMismatch
+	}
+	return version, nil
+}
+
+// notModified сообщает, совпадает ли один из ETag заголовка If-None-Match с версией записи.
+// If-None-Match сравнивает ETag нестрого, префикс W/ не учитывается
+func notModified(r *http.Request, version int) bool {
+	value := r.Header.Get("If-None-Match")
+	if value == "" {
+		return false
+	}
+	current := etag(version)
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header      string
+		wantVersion int
+		wantErr     error
+	}{
+		{"", 0, ErrPreconditionRequired},
+		{"*", 0, nil},
+		{`"3"`, 3, nil},
+		{` "3" `, 3, nil},
+		{`W/"3"`, 0, ErrVersionMismatch},
+		{`"abc"`, 0, ErrVersionMismatch},
+		{`"1", "2"`, 0, ErrInvalidIfMatch},
+		{`3`, 0, ErrInvalidIfMatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			version, err := ifMatch(r)
+			if !errors.Is(err, tt.wantErr) || version != tt.wantVersion {
+				t.Fatalf("ifMatch = %d, %v; want %d, %v", version, err, tt.wantVersion, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		api := newTestAPI(t, ds)
+		teacher := mustCreateTeacher(t, api.service, "Иван Петрович", "ivan@example.com")
+		target := "/teachers/" + strconv.Itoa(teacher.ID)
+		body := `{"name":"Иван","email":"ivan@example.com"}`
+
+		w := api.do(http.MethodGet, target, api.adminToken, "")
+		stale := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || stale != etag(teacher.Version) {
+			t.Fatalf("GET: status = %d, ETag = %q", w.Code, stale)
+		}
+		if w := api.do(http.MethodGet, target, api.adminToken, "", "If-None-Match", "W/"+stale); w.Code != http.StatusNotModified {
+			t.Fatalf("GET with matching If-None-Match: status = %d, want 304", w.Code)
+		}
+
+		if w := api.do(http.MethodPut, target, api.adminToken, body); w.Code != http.StatusPreconditionRequired {
+			t.Fatalf("PUT without If-Match: status = %d, want 428: %s", w.Code, w.Body)
+		}
+		w = api.do(http.MethodPut, target, api.adminToken, body, "If-Match", stale)
+		current := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || current == stale {
+			t.Fatalf("PUT with current If-Match: status = %d, ETag = %q: %s", w.Code, current, w.Body)
+		}
+
+		// Версия изменилась, старый ETag больше не подходит
+		tests := []struct {
+			name   string
+			method string
+			body   string
+		}{
+			{"put", http.MethodPut, body},
+			{"patch", http.MethodPatch, `{"name":"Иван Иванович"}`},
+			{"delete", http.MethodDelete, ""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := api.do(tt.method, target, api.adminToken, tt.body, "If-Match", stale)
+				if w.Code != http.StatusPreconditionFailed || errorCode(t, w) != ErrVersionMismatch.Code {
+					t.Fatalf("status = %d, want 412: %s", w.Code, w.Body)
+				}
+			})
+		}
+		if w := api.do(http.MethodGet, target, api.adminToken, "", "If-None-Match", stale); w.Code != http.StatusOK {
+			t.Fatalf("GET with stale If-None-Match: status = %d, want 200", w.Code)
+		}
+		if w := api.do(http.MethodDelete, target, api.adminToken, "", "If-Match", current); w.Code != http.StatusOK {
+			t.Fatalf("DELETE with current If-Match: status = %d: %s", w.Code, w.Body)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// migrationChecker хранилища, схема которых управляется миграциями
+type migrationChecker interface {
+	PendingMigrations(ctx context.Context) ([]Migration, error)
+}
+
+// DependencyStatus состояние одной зависимости в ответе /health/ready. Текст ошибки
+// в ответ не попадает: /health/ready открыт без токена, а ошибка драйвера может содержать
+// адрес и пользователя базы, поэтому она только пишется в лог
+type DependencyStatus struct {
+	Status  string   `json:"status"`
+	Latency string   `json:"latency,omitempty"`
+	Pending []string `json:"pending,omitempty"`
+}
+
+// ReadinessReport ответ /health/ready
+type ReadinessReport struct {
+	Status       string                      `json:"status"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// Health обработчики проверок живости и готовности
+type Health struct {
+	dataSource DataSource
+	timeout    time.Duration
+	logger     *slog.Logger
+	draining   atomic.Bool
+}
+
+// NewHealth создает новый экземпляр Health; timeout ограничивает каждую проверку зависимостей
+func NewHealth(dataSource DataSource, timeout time.Duration, logger *slog.Logger) *Health {
+	return &Health{
+		dataSource: dataSource,
+		timeout:    timeout,
+		logger:     logger,
+	}
+}
+
+// SetDraining переводит сервер в режим остановки: готовность начинает отвечать 503,
+// чтобы балансировщик перестал присылать новые запросы
+func (h *Health) SetDraining() {
+	h.draining.Store(true)
+}
+
+// LiveHandler отвечает 200, пока процесс способен обрабатывать запросы
+func (h *Health) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ReadyHandler проверяет хранилище и миграции и отвечает 503, если что-то недоступно
+func (h *Health) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	report := h.Check(r.Context())
+
+	code := http.StatusOK
+	if report.Status != "ready" {
+		code = http.StatusServiceUnavailable
+	}
+	respondWithJSON(w, code, report)
+}
+
+// Check выполняет проверки готовности
+func (h *Health) Check(ctx context.Context) ReadinessReport {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	report := ReadinessReport{Status: "ready", Dependencies: make(map[string]DependencyStatus)}
+
+	start := time.Now()
+	database := DependencyStatus{Status: "up"}
+	if err := h.dataSource.Ping(ctx); err != nil {
+		h.logger.Error("readiness check failed", "dependency", "database", "error", err)
+		database.Status = "down"
+	}
+	database.Latency = time.Since(start).Round(time.Microsecond).String()
+	report.Dependencies["database"] = database
+
+	if checker, ok := h.dataSource.(migrationChecker); ok {
+		migrations := DependencyStatus{Status: "up"}
+		pending, err := checker.PendingMigrations(ctx)
+		switch {
+		case err != nil:
+			h.logger.Error("readiness check failed", "dependency", "migrations", "error", err)
+			migrations.Status = "down"
+		case len(pending) > 0:
+			migrations.Status = "down"
+			for _, m := range pending {
+				migrations.Pending = append(migrations.Pending, fmt.Sprintf("%04d_%s", m.Version, m.Name))
+			}
+		}
+		report.Dependencies["migrations"] = migrations
+	}
+
+	if h.draining.Load() {
+		report.Status = "draining"
+		return report
+	}
+	for _, dependency := range report.Dependencies {
+		if dependency.Status != "up" {
+			report.Status = "not_ready"
+		}
+	}
+	return report
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unreachableDataSource хранилище, до которого нельзя достучаться
+type unreachableDataSource struct {
+	DataSource
+}
+
+func (unreachableDataSource) Ping(ctx context.Context) error {
+	return errors.New("dial tcp db.internal:5432: connection refused")
+}
+
+func TestReadiness(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		tests := []struct {
+			name       string
+			dataSource DataSource
+			draining   bool
+			wantStatus int
+			wantReport string
+		}{
+			{"ready", ds, false, http.StatusOK, "ready"},
+			{"database down", unreachableDataSource{ds}, false, http.StatusServiceUnavailable, "not_ready"},
+			{"draining", ds, true, http.StatusServiceUnavailable, "draining"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				var logs bytes.Buffer
+				health := NewHealth(tt.dataSource, time.Second, slog.New(slog.NewTextHandler(&logs, nil)))
+				if tt.draining {
+					health.SetDraining()
+				}
+				w := serveJSON(health.ReadyHandler, http.MethodGet, "/health/ready", "")
+				var report ReadinessReport
+				body := w.Body.String()
+				decodeBody(t, w, &report)
+				if w.Code != tt.wantStatus || report.Status != tt.wantReport {
+					t.Fatalf("status = %d, report = %+v", w.Code, report)
+				}
+				// Адрес базы из ошибки драйвера виден только в логе
+				if strings.Contains(body, "db.internal") {
+					t.Fatalf("response exposes the error: %s", body)
+				}
+				if tt.wantReport == "not_ready" && !strings.Contains(logs.String(), "db.internal") {
+					t.Fatalf("error is not logged: %s", logs.String())
+				}
+				// Живость не зависит от хранилища и остановки
+				if w := serveJSON(health.LiveHandler, http.MethodGet, "/health/live", ""); w.Code != http.StatusOK {
+					t.Fatalf("live status = %d", w.Code)
+				}
+			})
+		}
+	})
+}
@@ -1,17 +1,50 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 )
 
 // GetAllTeachersHandler обработчик для получения всех преподавателей
 func (c *Controller) GetAllTeachersHandler(w http.ResponseWriter, r *http.Request) {
-	data, err := c.service.GetAllTeachers()
+	if err := c.authorize(r, ActionListTeachers, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	params, err := parseListParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	data, err := c.service.GetAllTeachers(r.Context(), params)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, data)
+}
+
+// GetTeacherHandler обработчик для получения преподавателя по ID
+func (c *Controller) GetTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionReadTeacher, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	data, err := c.service.GetTeacherByID(r.Context(), id)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Неверный формат JSON")
+		respondWithError(w, err)
+		return
+	}
+	setETag(w, data.Version)
+	if notModified(r, data.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 	respondWithJSON(w, http.StatusOK, data)
@@ -19,128 +52,673 @@ func (c *Controller) GetAllTeachersHandler(w http.ResponseWriter, r *http.Reques
 
 // CreateTeacherHandler обработчик для создания нового преподавателя
 func (c *Controller) CreateTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	if err := c.authorize(r, ActionCreateTeacher, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
 	var teacher Teacher
-	err := json.NewDecoder(r.Body).Decode(&teacher)
+	err := decodeJSON(r, &teacher)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Неверный формат JSON")
+		respondWithError(w, err)
 		return
 	}
-	defer r.Body.Close()
 
-	c.service.CreateTeacher(teacher)
+	teacher, err = c.service.CreateTeacher(r.Context(), teacher)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	// Преподаватель получает приглашение сразу; если письмо не ушло, администратор
+	// может повторить его через POST /accounts/invitations — будет отправлена новая ссылка
+	if _, err := c.accounts.InviteTeacher(r.Context(), teacher.ID); err != nil {
+		c.logger.ErrorContext(r.Context(), "teacher invitation failed", "teacher_id", teacher.ID, "error", err)
+	}
 	respondWithJSON(w, http.StatusCreated, map[string]string{"message": "Преподаватель успешно создан"})
 }
 
 // UpdateTeacherHandler обработчик для обновления данных преподавателя
 func (c *Controller) UpdateTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionUpdateTeacher, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
 	var teacher Teacher
-	err := json.NewDecoder(r.Body).Decode(&teacher)
+	err = decodeJSON(r, &teacher)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Неверный формат JSON")
+		respondWithError(w, err)
 		return
 	}
-	defer r.Body.Close()
+	teacher.ID, teacher.Version = id, version
 
-	err = c.service.UpdateTeacher(teacher)
+	teacher, err = c.service.UpdateTeacher(r.Context(), teacher)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, err.Error())
+		respondWithError(w, err)
 		return
 	}
+	setETag(w, teacher.Version)
 
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Преподаватель успешно обновлен"})
 }
 
+// PatchTeacherHandler обработчик для частичного обновления преподавателя (JSON Merge Patch)
+func (c *Controller) PatchTeacherHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionUpdateTeacher, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	patch, err := decodeMergePatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	teacher, err := c.service.PatchTeacher(r.Context(), id, version, patch)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	setETag(w, teacher.Version)
+	respondWithJSON(w, http.StatusOK, teacher)
+}
+
 // DeleteTeacherHandler обработчик для удаления преподавателя
 func (c *Controller) DeleteTeacherHandler(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		ID int `json:"id"`
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
 	}
-	err := json.NewDecoder(r.Body).Decode(&req)
+	if err := c.authorize(r, ActionDeleteTeacher, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	version, err := ifMatch(r)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Неверный формат JSON")
+		respondWithError(w, err)
+		return
+	}
+
+	// ?reassign_to=<id> передает курсы преподавателя другому в той же транзакции, что и удаление
+	if value := r.URL.Query().Get("reassign_to"); value != "" {
+		successorID, err := strconv.Atoi(value)
+		if err != nil || successorID <= 0 {
+			respondWithError(w, invalidParam("reassign_to", "reassign_to must be a positive teacher id"))
+			return
+		}
+		reassigned, err := c.service.ReassignAndDeleteTeacher(r.Context(), id, version, successorID)
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"message":            "Преподаватель успешно удален",
+			"reassigned_courses": reassigned,
+		})
 		return
 	}
-	defer r.Body.Close()
 
-	c.service.DeleteTeacher(req.ID)
+	err = c.service.DeleteTeacher(r.Context(), id, version)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
 
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Преподаватель успешно удален"})
 }
 
-// // GetAllCoursesHandler обработчик для получения всех курсов
-// func (c *Controller) GetAllCoursesHandler(w http.ResponseWriter, r *http.Request) {
-// 	data := c.service.GetAll("course")
-// 	respondWithJSON(w, http.StatusOK, data)
-// }
+// GetAllCoursesHandler обработчик для получения всех курсов
+func (c *Controller) GetAllCoursesHandler(w http.ResponseWriter, r *http.Request) {
+	if err := c.authorize(r, ActionListCourses, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	params, err := parseListParams(r.URL.Query())
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	data, err := c.service.GetAllCourses(r.Context(), params)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, data)
+}
+
+// GetCourseHandler обработчик для получения курса по ID
+func (c *Controller) GetCourseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionReadCourse, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	data, err := c.service.GetCourseByID(r.Context(), id)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	setETag(w, data.Version)
+	if notModified(r, data.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, data)
+}
+
+// CreateCourseHandler обработчик для создания нового курса
+func (c *Controller) CreateCourseHandler(w http.ResponseWriter, r *http.Request) {
+	if err := c.authorize(r, ActionCreateCourse, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	var req struct {
+		Course
+		// StudentIDs студенты, которых нужно записать на курс вместе с его созданием
+		StudentIDs []int `json:"student_ids"`
+	}
+	err := decodeJSON(r, &req)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	if len(req.StudentIDs) > 0 {
+		if err := c.authorize(r, ActionEnroll, Resource{}); err != nil {
+			respondWithError(w, err)
+			return
+		}
+		_, enrollments, err := c.service.CreateCourseWithStudents(r.Context(), req.Course, req.StudentIDs)
+		if err != nil {
+			respondWithError(w, err)
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, map[string]interface{}{
+			"message":     "Курс успешно создан",
+			"enrollments": enrollments,
+		})
+		return
+	}
+
+	_, err = c.service.CreateCourse(r.Context(), req.Course)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, map[string]string{"message": "Курс успешно создан"})
+}
 
 // UpdateCourseHandler обработчик для обновления данных курса
 func (c *Controller) UpdateCourseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
 	var course Course
-	err := json.NewDecoder(r.Body).Decode(&course)
+	err = decodeJSON(r, &course)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Неверный формат JSON")
+		respondWithError(w, err)
+		return
+	}
+	course.ID, course.Version = id, version
+	// Преподаватель не может передать свой курс другому преподавателю. Новый владелец известен
+	// из тела, поэтому проверяется до обращения к хранилищу и не выдает, есть ли такой курс
+	if err := c.authorize(r, ActionUpdateCourse, Resource{TeacherID: course.TeacherID}); err != nil {
+		respondWithError(w, err)
 		return
 	}
-	defer r.Body.Close()
 
-	// todo
-	// err = c.service.Update("course", id, course)
-	// if err != nil {
-	// 	respondWithError(w, http.StatusNotFound, err.Error())
-	// 	return
-	// }
+	// Текущий владелец проверяется внутри записи: курс могли передать другому преподавателю
+	// после того, как клиент его прочитал
+	course, err = c.service.UpdateCourse(r.Context(), course, func(current, updated Course) error {
+		return c.authorize(r, ActionUpdateCourse, Resource{TeacherID: current.TeacherID})
+	})
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	setETag(w, course.Version)
 
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Курс успешно обновлен"})
 }
 
+// PatchCourseHandler обработчик для частичного обновления курса (JSON Merge Patch).
+// Права проверяются по владельцу курса до и после изменения
+func (c *Controller) PatchCourseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	patch, err := decodeMergePatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	course, err := c.service.PatchCourse(r.Context(), id, version, patch, func(current, patched Course) error {
+		if err := c.authorize(r, ActionUpdateCourse, Resource{TeacherID: current.TeacherID}); err != nil {
+			return err
+		}
+		// Преподаватель не может передать свой курс другому преподавателю
+		return c.authorize(r, ActionUpdateCourse, Resource{TeacherID: patched.TeacherID})
+	})
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	setETag(w, course.Version)
+	respondWithJSON(w, http.StatusOK, course)
+}
+
+// DeleteCourseHandler обработчик для удаления курса
+func (c *Controller) DeleteCourseHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionDeleteCourse, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	err = c.service.DeleteCourse(r.Context(), id, version)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Курс успешно удален"})
+}
+
 func (c *Controller) GetAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
-	data, err := c.service.GetAllStudents()
+	if err := c.authorize(r, ActionListStudents, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	params, err := parseListParams(r.URL.Query())
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Неверный формат JSON")
+		respondWithError(w, err)
+		return
+	}
+
+	data, err := c.service.GetAllStudents(r.Context(), params)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	c.logger.DebugContext(r.Context(), "students listed", "total", data.Total, "returned", len(data.Items))
+	respondWithJSON(w, http.StatusOK, data)
+}
+
+// GetStudentHandler обработчик для получения студента по ID
+func (c *Controller) GetStudentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionReadStudent, Resource{StudentID: id}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	data, err := c.service.GetStudentByID(r.Context(), id)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	setETag(w, data.Version)
+	if notModified(r, data.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
-	fmt.Println("Get all students: found : ", len(data))
 	respondWithJSON(w, http.StatusOK, data)
 }
 
 // CreateStudentHandler обработчик для создания нового студента
 func (c *Controller) CreateStudentHandler(w http.ResponseWriter, r *http.Request) {
+	if err := c.authorize(r, ActionCreateStudent, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
 	var student Student
-	err := json.NewDecoder(r.Body).Decode(&student)
+	err := decodeJSON(r, &student)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Неверный формат JSON")
+		respondWithError(w, err)
 		return
 	}
-	defer r.Body.Close()
 
-	c.service.CreateStudent(student)
+	_, err = c.service.CreateStudent(r.Context(), student)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
 	respondWithJSON(w, http.StatusCreated, map[string]string{"message": "Студент успешно создан"})
 }
 
 // UpdateStudentHandler обработчик для обновления данных студента
 func (c *Controller) UpdateStudentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionUpdateStudent, Resource{StudentID: id}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
 	var student Student
-	err := json.NewDecoder(r.Body).Decode(&student)
+	err = decodeJSON(r, &student)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Неверный формат JSON")
+		respondWithError(w, err)
 		return
 	}
-	defer r.Body.Close()
+	student.ID, student.Version = id, version
 
-	c.service.UpdateStudent(student)
+	student, err = c.service.UpdateStudent(r.Context(), student)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	setETag(w, student.Version)
 
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Студент успешно обновлен"})
 }
 
+// PatchStudentHandler обработчик для частичного обновления студента (JSON Merge Patch)
+func (c *Controller) PatchStudentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionUpdateStudent, Resource{StudentID: id}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	version, err := ifMatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	patch, err := decodeMergePatch(r)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	student, err := c.service.PatchStudent(r.Context(), id, version, patch)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	setETag(w, student.Version)
+	respondWithJSON(w, http.StatusOK, student)
+}
+
 // DeleteStudentHandler обработчик для удаления студента
 func (c *Controller) DeleteStudentHandler(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	id, err := strconv.Atoi(idStr)
+	id, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionDeleteStudent, Resource{StudentID: id}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	version, err := ifMatch(r)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Неверный ID")
+		respondWithError(w, err)
+		return
+	}
+
+	err = c.service.DeleteStudent(r.Context(), id, version)
+	if err != nil {
+		respondWithError(w, err)
 		return
 	}
-	c.service.DeleteStudent(id)
 
 	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Студент успешно удален"})
 }
+
+// GetCourseStudentsHandler обработчик для получения студентов, записанных на курс
+func (c *Controller) GetCourseStudentsHandler(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	course, err := c.service.GetCourseByID(r.Context(), courseID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	if err := c.authorize(r, ActionListCourseStudents, Resource{TeacherID: course.TeacherID}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	data, err := c.service.GetCourseStudents(r.Context(), courseID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, data)
+}
+
+// GetStudentCoursesHandler обработчик для получения курсов студента
+func (c *Controller) GetStudentCoursesHandler(w http.ResponseWriter, r *http.Request) {
+	studentID, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionListStudentCourses, Resource{StudentID: studentID}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	data, err := c.service.GetStudentCourses(r.Context(), studentID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, data)
+}
+
+// EnrollStudentHandler обработчик для записи студента на курс
+func (c *Controller) EnrollStudentHandler(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionEnroll, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	var req struct {
+		StudentID int `json:"student_id"`
+	}
+	err = decodeJSON(r, &req)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	enrollment, err := c.service.EnrollStudent(r.Context(), req.StudentID, courseID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, enrollment)
+}
+
+// UnenrollStudentHandler обработчик для отписки студента от курса
+func (c *Controller) UnenrollStudentHandler(w http.ResponseWriter, r *http.Request) {
+	courseID, err := pathID(r, "id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+	if err := c.authorize(r, ActionUnenroll, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	studentID, err := pathID(r, "student_id")
+	if err != nil {
+		respondWithError(w, ErrInvalidID)
+		return
+	}
+
+	err = c.service.UnenrollStudent(r.Context(), studentID, courseID)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Студент отписан от курса"})
+}
+
+// InviteUserHandler обработчик для приглашения преподавателя или студента: создает учетную запись
+// без пароля и отправляет письмо со ссылкой
+func (c *Controller) InviteUserHandler(w http.ResponseWriter, r *http.Request) {
+	if err := c.authorize(r, ActionInviteUser, Resource{}); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	var req struct {
+		TeacherID int `json:"teacher_id"`
+		StudentID int `json:"student_id"`
+	}
+	err := decodeJSON(r, &req)
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	var user User
+	switch {
+	case req.TeacherID != 0 && req.StudentID == 0:
+		user, err = c.accounts.InviteTeacher(r.Context(), req.TeacherID)
+	case req.StudentID != 0 && req.TeacherID == 0:
+		user, err = c.accounts.InviteStudent(r.Context(), req.StudentID)
+	default:
+		err = invalidFields(FieldError{Field: "teacher_id", Code: "required", Message: "exactly one of teacher_id and student_id is required"})
+	}
+	if err != nil {
+		respondWithError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, user)
+}
+
+// passwordRequest тело запросов, задающих пароль по одноразовому токену
+type passwordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// AcceptInvitationHandler обработчик для принятия приглашения: задает пароль
+func (c *Controller) AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	var req passwordRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	if err := c.accounts.AcceptInvitation(r.Context(), req.Token, req.Password); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Пароль задан, теперь можно войти"})
+}
+
+// RequestPasswordResetHandler обработчик для запроса сброса пароля. Ответ одинаков
+// независимо от того, существует ли пользователь
+func (c *Controller) RequestPasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	if err := c.accounts.RequestPasswordReset(r.Context(), req.Username); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Если учетная запись существует, на нее отправлено письмо"})
+}
+
+// ResetPasswordHandler обработчик для установки нового пароля по токену сброса
+func (c *Controller) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req passwordRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondWithError(w, err)
+		return
+	}
+
+	if err := c.accounts.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		respondWithError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"message": "Пароль изменен"})
+}
+
+// pathID разбирает числовой параметр пути, например {id} в /teachers/{id}
+func pathID(r *http.Request, name string) (int, error) {
+	return strconv.Atoi(r.PathValue(name))
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// Колонки, по которым разрешена сортировка списков
+var (
+	teacherSortColumns = []string{"id", "name", "email"}
+	studentSortColumns = []string{"id", "name", "email"}
+	courseSortColumns  = []string{"id", "title", "price", "teacher_id"}
+
+	// textSortColumns строковые колонки: сравниваются побайтно (COLLATE "C" в SQL)
+	textSortColumns = map[string]bool{"name": true, "email": true, "title": true}
+)
+
+// ListParams параметры выборки списка: страница (limit/offset или cursor),
+// сортировка и фильтры по префиксу
+type ListParams struct {
+	Limit  int
+	Offset int
+	Cursor string
+	Sort   string
+	Desc   bool
+	// Name фильтр по префиксу имени (для курсов — названия)
+	Name string
+	// Email фильтр по префиксу email
+	Email string
+
+	// after позиция, с которой продолжается выборка; заполняется из Cursor
+	after *cursorPosition
+}
+
+// Page страница списка с общим числом записей, подходящих под фильтры
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Total      int    `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// cursorPosition последняя выданная запись: значение колонки сортировки и ID для однозначности
+type cursorPosition struct {
+	Sort  string      `json:"s"`
+	Desc  bool        `json:"d,omitempty"`
+	Value interface{} `json:"v"`
+	ID    int         `json:"id"`
+}
+
+// sortable запись, которую можно упорядочить по колонке из списка *SortColumns
+type sortable interface {
+	key() int
+	sortValue(column string) interface{}
+}
+
+func (t Teacher) key() int { return t.ID }
+func (s Student) key() int { return s.ID }
+func (c Course) key() int  { return c.ID }
+
+func (t Teacher) sortValue(column string) interface{} {
+	switch column {
+	case "name":
+		return t.Name
+	case "email":
+		return t.Email
+	}
+	return t.ID
+}
+
+func (s Student) sortValue(column string) interface{} {
+	switch column {
+	case "name":
+		return s.Name
+	case "email":
+		return s.Email
+	}
+	return s.ID
+}
+
+func (c Course) sortValue(column string) interface{} {
+	switch column {
+	case "title":
+		return c.Title
+	case "price":
+		return c.Price
+	case "teacher_id":
+		return c.TeacherID
+	}
+	return c.ID
+}
+
+// parseListParams читает параметры списка из строки запроса:
+// limit, offset, cursor, sort, order=asc|desc, name, email
+func parseListParams(query url.Values) (ListParams, error) {
+	params := ListParams{
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+		Name:   query.Get("name"),
+		Email:  query.Get("email"),
+	}
+
+	var err error
+	if v := query.Get("limit"); v != "" {
+		if params.Limit, err = strconv.Atoi(v); err != nil || params.Limit <= 0 {
+			return ListParams{}, invalidParam("limit", "limit must be a positive integer")
+		}
+	}
+	if v := query.Get("offset"); v != "" {
+		if params.Offset, err = strconv.Atoi(v); err != nil || params.Offset < 0 {
+			return ListParams{}, invalidParam("offset", "offset must be a non-negative integer")
+		}
+	}
+	switch strings.ToLower(query.Get("order")) {
+	case "", "asc":
+	case "desc":
+		params.Desc = true
+	default:
+		return ListParams{}, invalidParam("order", "order must be asc or desc")
+	}
+	return params, nil
+}
+
+// normalize подставляет значения по умолчанию, проверяет колонку сортировки и разбирает курсор
+func (p ListParams) normalize(columns []string) (ListParams, error) {
+	if p.Sort == "" {
+		p.Sort = "id"
+	}
+	if !containsString(columns, p.Sort) {
+		return ListParams{}, invalidParam("sort", fmt.Sprintf("sort must be one of %s", strings.Join(columns, ", ")))
+	}
+	if p.Limit <= 0 {
+		p.Limit = defaultPageLimit
+	}
+	if p.Limit > maxPageLimit {
+		p.Limit = maxPageLimit
+	}
+
+	if p.Cursor != "" {
+		position, err := decodeCursor(p.Cursor)
+		_, isText := position.Value.(string)
+		_, isNumber := position.Value.(float64)
+		valid := err == nil && position.Sort == p.Sort && position.Desc == p.Desc &&
+			(isText && textSortColumns[p.Sort] || isNumber && !textSortColumns[p.Sort])
+		if !valid {
+			return ListParams{}, invalidParam("cursor", "cursor is malformed or was issued for a different sort")
+		}
+		p.after = &position
+		p.Offset = 0
+	}
+	return p, nil
+}
+
+// newPage обрезает выборку до Limit и, если записей больше, выдает курсор на следующую страницу.
+// Хранилища запрашивают Limit+1 запись, чтобы узнать, есть ли продолжение
+func newPage[T sortable](items []T, total int, params ListParams) Page[T] {
+	page := Page[T]{Items: items, Total: total, Limit: params.Limit, Offset: params.Offset}
+	if len(items) > params.Limit {
+		page.Items = items[:params.Limit]
+		last := page.Items[len(page.Items)-1]
+		page.NextCursor = encodeCursor(cursorPosition{
+			Sort:  params.Sort,
+			Desc:  params.Desc,
+			Value: last.sortValue(params.Sort),
+			ID:    last.key(),
+		})
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page
+}
+
+// paginate применяет ListParams к записям хранилища в памяти так же, как это делает SQL-запрос
+func paginate[T sortable](items []T, params ListParams, match func(T) bool) Page[T] {
+	filtered := make([]T, 0, len(items))
+	for _, item := range items {
+		if match(item) {
+			filtered = append(filtered, item)
+		}
+	}
+	total := len(filtered)
+
+	less := func(a, b T) bool {
+		if c := compareValues(a.sortValue(params.Sort), b.sortValue(params.Sort)); c != 0 {
+			return (c < 0) != params.Desc
+		}
+		return (a.key() < b.key()) != params.Desc
+	}
+	sort.SliceStable(filtered, func(i, j int) bool { return less(filtered[i], filtered[j]) })
+
+	start := params.Offset
+	if params.after != nil {
+		start = sort.Search(len(filtered), func(i int) bool {
+			item := filtered[i]
+			c := compareValues(item.sortValue(params.Sort), params.after.Value)
+			if c == 0 {
+				c = compareValues(item.key(), params.after.ID)
+			}
+			if params.Desc {
+				return c < 0
+			}
+			return c > 0
+		})
+	}
+	if start > len(filtered) {
+		start = len(filtered)
+	}
+	end := start + params.Limit + 1
+	if end > len(filtered) {
+		end = len(filtered)
+	}
+
+	return newPage(filtered[start:end], total, params)
+}
+
+// hasPrefixFold проверяет префикс без учета регистра; пустой префикс подходит всегда
+func hasPrefixFold(s, prefix string) bool {
+	return strings.HasPrefix(foldCase(s), foldCase(prefix))
+}
+
+// foldCase приводит строку к нижнему регистру для фильтров по префиксу. Все хранилища
+// сравнивают строки, приведенные этой функцией: память — напрямую, SQLite — через
+// SQL-функцию fold_case, PostgreSQL — через lower(), который для UTF-8 базы дает тот же результат
+func foldCase(s string) string {
+	return strings.ToLower(s)
+}
+
+// compareValues сравнивает значения колонок; числа из курсора приходят как float64
+func compareValues(a, b interface{}) int {
+	if as, ok := a.(string); ok {
+		bs, _ := b.(string)
+		return strings.Compare(as, bs)
+	}
+	af, bf := toFloat(a), toFloat(b)
+	switch {
+	case af < bf:
+		return -1
+	case af > bf:
+		return 1
+	}
+	return 0
+}
+
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+func encodeCursor(position cursorPosition) string {
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (cursorPosition, error) {
+	var position cursorPosition
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return position, err
+	}
+	err = json.Unmarshal(data, &position)
+	return position, err
+}
+
+// invalidParam ошибка валидации параметра строки запроса
+func invalidParam(field, message string) error {
+	return &Error{Kind: KindValidation, Code: "invalid_" + field, Field: field, Message: message}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// names проходит все страницы списка студентов по курсору и возвращает имена в порядке выдачи
+func names(t *testing.T, s *Service, params ListParams) []string {
+	t.Helper()
+	var got []string
+	for page := 0; ; page++ {
+		if page > 10 {
+			t.Fatal("cursor paging does not terminate")
+		}
+		result, err := s.GetAllStudents(context.Background(), params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, student := range result.Items {
+			got = append(got, student.Name)
+		}
+		if result.NextCursor == "" {
+			return got
+		}
+		params.Cursor = result.NextCursor
+	}
+}
+
+func TestCursorPaging(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		s := newTestService(ds)
+		// Одинаковые имена проверяют, что при равных значениях порядок задает ID
+		for _, student := range []Student{
+			{Name: "Борис", Email: "boris@example.com"},
+			{Name: "Анна", Email: "anna@example.com"},
+			{Name: "Вера", Email: "vera@example.com"},
+			{Name: "Анна", Email: "anna2@example.com"},
+			{Name: "Глеб", Email: "gleb@example.com"},
+		} {
+			mustCreateStudent(t, s, student.Name, student.Email)
+		}
+
+		tests := []struct {
+			name   string
+			params ListParams
+			want   []string
+		}{
+			{"by id", ListParams{Limit: 2}, []string{"Борис", "Анна", "Вера", "Анна", "Глеб"}},
+			{"by name", ListParams{Limit: 2, Sort: "name"}, []string{"Анна", "Анна", "Борис", "Вера", "Глеб"}},
+			{"by name desc", ListParams{Limit: 2, Sort: "name", Desc: true}, []string{"Глеб", "Вера", "Борис", "Анна", "Анна"}},
+			// Регистр не учитывается и для кириллицы на всех хранилищах
+			{"with prefix filter", ListParams{Limit: 1, Sort: "name", Name: "ан"}, []string{"Анна", "Анна"}},
+			{"with upper-case prefix", ListParams{Sort: "name", Name: "ВЕ"}, []string{"Вера"}},
+			{"with email prefix", ListParams{Sort: "name", Email: "ANNA2"}, []string{"Анна"}},
+			{"like characters are literal", ListParams{Name: "%"}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := names(t, s, tt.params); !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("names = %v, want %v", got, tt.want)
+				}
+			})
+		}
+
+		t.Run("cursor from another sort", func(t *testing.T) {
+			page, err := s.GetAllStudents(context.Background(), ListParams{Limit: 2, Sort: "name"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.GetAllStudents(context.Background(), ListParams{Limit: 2, Sort: "email", Cursor: page.NextCursor})
+			var domainErr *Error
+			if !errors.As(err, &domainErr) || domainErr.Field != "cursor" {
+				t.Fatalf("err = %v, want invalid cursor", err)
+			}
+		})
+	})
+}
+
+func TestListResponse(t *testing.T) {
+	api := newTestAPI(t, NewMemoryDataSource())
+	mustCreateStudent(t, api.service, "Анна", "anna@example.com")
+	mustCreateStudent(t, api.service, "Борис", "boris@example.com")
+
+	w := api.do(http.MethodGet, "/students?limit=1&sort=name", api.adminToken, "")
+	var page Page[Student]
+	decodeBody(t, w, &page)
+	if w.Code != http.StatusOK || page.Total != 2 || len(page.Items) != 1 || page.NextCursor == "" {
+		t.Fatalf("status = %d, page = %+v", w.Code, page)
+	}
+	w = api.do(http.MethodGet, "/students?limit=1&sort=name&cursor="+page.NextCursor, api.adminToken, "")
+	page = Page[Student]{}
+	decodeBody(t, w, &page)
+	if len(page.Items) != 1 || page.Items[0].Name != "Борис" || page.NextCursor != "" {
+		t.Fatalf("second page = %+v", page)
+	}
+
+	if w := api.do(http.MethodGet, "/students?limit=0", api.adminToken, ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("limit=0: status = %d, want 400: %s", w.Code, w.Body)
+	}
+}
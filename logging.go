@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// requestIDHeader заголовок с идентификатором запроса; входящее значение сохраняется,
+// чтобы один запрос можно было проследить через несколько сервисов
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает длину принятого от клиента идентификатора
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// newLogger создает логгер с уровнем log_level и форматом log_format (text или json)
+func newLogger(out io.Writer, level, format string) *slog.Logger {
+	var lvl slog.Level
+	lvl.UnmarshalText([]byte(level))
+
+	options := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(out, options)
+	} else {
+		handler = slog.NewTextHandler(out, options)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// contextHandler добавляет к записям лога request_id из контекста,
+// поэтому вызовы вида logger.InfoContext(r.Context(), ...) привязаны к запросу
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// requestID возвращает идентификатор запроса, сохраненный withRequestID
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID берет идентификатор запроса из X-Request-ID или генерирует новый,
+// кладет его в контекст и возвращает клиенту в том же заголовке
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// validRequestID допускает только печатные ASCII-символы, чтобы идентификатор не ломал строки лога
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// accessRecorder запоминает код ответа, размер тела и ошибку, переданную respondWithError
+type accessRecorder struct {
+	http.ResponseWriter
+	code  int
+	bytes int
+	err   error
+}
+
+func (r *accessRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *accessRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *accessRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// recordError сохраняет ошибку обработчика для строки access-лога,
+// если ответ проходит через accessLog (возможно, под другими обертками)
+func recordError(w http.ResponseWriter, err error) {
+	for {
+		if recorder, ok := w.(*accessRecorder); ok {
+			recorder.err = err
+			return
+		}
+		unwrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return
+		}
+		w = unwrapper.Unwrap()
+	}
+}
+
+// accessLog пишет одну строку лога на каждый запрос. Ответы 5xx пишутся с уровнем ERROR
+// вместе с исходной ошибкой, которую клиент не видит; запросы, прерванные клиентом (499), —
+// с уровнем WARN: это не сбой сервера, но частые отмены говорят о медленных ответах
+func accessLog(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &accessRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", recorder.code),
+			slog.Int("bytes", recorder.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote_addr", r.RemoteAddr),
+		}
+		level := slog.LevelInfo
+		switch {
+		case recorder.code >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case recorder.code == statusClientClosedRequest:
+			level = slog.LevelWarn
+		}
+		if recorder.err != nil {
+			attrs = append(attrs, slog.String("error", recorder.err.Error()))
+		}
+		logger.LogAttrs(r.Context(), level, "http request", attrs...)
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestIDInAccessLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"kept from client", "trace-42", true},
+		{"generated when missing", "", false},
+		{"replaced when not printable", "bad id\n", false},
+		{"replaced when too long", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := newLogger(&out, "info", "json")
+			handler := withRequestID(accessLog(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				respondWithError(w, errors.New("boom"))
+			})))
+
+			r := httptest.NewRequest(http.MethodGet, "/teachers", nil)
+			if tt.incoming != "" {
+				r.Header.Set(requestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			id := w.Header().Get(requestIDHeader)
+			if tt.keep && id != tt.incoming || !tt.keep && (id == tt.incoming || !validRequestID(id)) {
+				t.Fatalf("%s = %q", requestIDHeader, id)
+			}
+			var entry struct {
+				Msg       string `json:"msg"`
+				Level     string `json:"level"`
+				Status    int    `json:"status"`
+				RequestID string `json:"request_id"`
+				Error     string `json:"error"`
+			}
+			if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+				t.Fatalf("decode log %q: %v", out.String(), err)
+			}
+			if entry.Msg != "http request" || entry.RequestID != id || entry.Status != http.StatusInternalServerError ||
+				entry.Level != "ERROR" || entry.Error == "" {
+				t.Fatalf("access log = %s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Mail письмо пользователю
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма; реализацию можно заменить на SMTP или внешний сервис
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// FileMailer вместо отправки сохраняет каждое письмо в отдельный .eml файл каталога dir.
+// Подходит для разработки: ссылки из приглашений и сброса пароля берутся из файлов
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer создает новый экземпляр FileMailer
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(mail Mail) error {
+	// Письма содержат одноразовые токены, поэтому доступны только владельцу процесса
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return fmt.Errorf("mail dir: %w", err)
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mail.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(mail.Body, "\n", "\r\n"))
+
+	return os.WriteFile(filepath.Join(m.dir, name), []byte(b.String()), 0o600)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeedData(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		ctx := context.Background()
+		s := newTestService(ds)
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+		// Существующий преподаватель сдвигает идентификаторы демонстрационных
+		existing := mustCreateTeacher(t, s, "Иван Петрович", "ivan@example.com")
+		seedData(ctx, s, "if-empty", logger)
+		if teachers, _ := s.GetAllTeachers(ctx, ListParams{}); teachers.Total != 1 {
+			t.Fatalf("if-empty seeded a non-empty store: %d teachers", teachers.Total)
+		}
+
+		seedData(ctx, s, "always", logger)
+		if logs.Len() != 0 {
+			t.Fatalf("seed logged: %s", logs.String())
+		}
+		teachers, err := s.GetAllTeachers(ctx, ListParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[int]bool)
+		for _, teacher := range teachers.Items {
+			if teacher.ID != existing.ID {
+				ids[teacher.ID] = true
+			}
+		}
+		courses, err := s.GetAllCourses(ctx, ListParams{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ids) != 2 || courses.Total != 2 {
+			t.Fatalf("seeded %d teachers and %d courses", len(ids), courses.Total)
+		}
+		for _, course := range courses.Items {
+			if !ids[course.TeacherID] {
+				t.Fatalf("course %q belongs to teacher %d, not a seeded one", course.Title, course.TeacherID)
+			}
+		}
+
+		// Повторное заполнение упирается в занятые адреса, и ошибка попадает в лог
+		seedData(ctx, s, "always", logger)
+		if !strings.Contains(logs.String(), "seed failed") || !strings.Contains(logs.String(), "create teacher") {
+			t.Fatalf("seed failure is not logged: %s", logs.String())
+		}
+	})
+}
+
+// startServe запускает serve с обработчиком handler и возвращает адрес сервера,
+// функцию остановки, канал с результатом serve и буфер логов
+func startServe(t *testing.T, handler http.Handler, shutdownTimeout time.Duration) (string, context.CancelFunc, *Health, <-chan error, *bytes.Buffer) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	health := NewHealth(NewMemoryDataSource(), time.Second, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(ctx, &http.Server{Handler: handler}, listener, health, shutdownTimeout, logger)
+	}()
+	return "http://" + listener.Addr().String(), cancel, health, done, &logs
+}
+
+// blockingHandler отвечает 200 после закрытия release и сообщает в started о начале запроса
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	addr, shutdown, health, done, logs := startServe(t, blockingHandler(started, release), 5*time.Second)
+
+	response := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(addr)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				err = fmt.Errorf("status = %d", resp.StatusCode)
+			}
+		}
+		response <- err
+	}()
+	<-started
+	shutdown()
+
+	// Пока запрос выполняется, сервер остается в режиме остановки и не завершается
+	deadline := time.Now().Add(5 * time.Second)
+	for health.Check(context.Background()).Status != "draining" {
+		if time.Now().After(deadline) {
+			t.Fatal("health did not switch to draining")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("serve returned before the request finished: %v", err)
+	default:
+	}
+
+	close(release)
+	if err := <-response; err != nil {
+		t.Fatalf("in-flight request: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logs.String(), "http server stopped") {
+		t.Fatalf("logs: %s", logs)
+	}
+	if _, err := http.Get(addr); err == nil {
+		t.Fatal("server accepts connections after shutdown")
+	}
+}
+
+func TestServeClosesConnectionsAfterShutdownTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	addr, shutdown, _, done, logs := startServe(t, blockingHandler(started, release), 50*time.Millisecond)
+
+	response := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(addr)
+		if err == nil {
+			resp.Body.Close()
+		}
+		response <- err
+	}()
+	<-started
+	shutdown()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-response; err == nil {
+		t.Fatal("request outliving the drain period was not cut off")
+	}
+	if !strings.Contains(logs.String(), "drain period expired") {
+		t.Fatalf("logs: %s", logs)
+	}
+}
@@ -0,0 +1,659 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+type enrollmentKey struct {
+	studentID int
+	courseID  int
+}
+
+// MemoryDataSource хранилище в памяти, используется для демонстраций и тестов без базы данных.
+// Операции не ждут ни ввода-вывода, ни блокировок дольше мгновения, поэтому ctx проверяется
+// только перед фиксацией Transact
+type MemoryDataSource struct {
+	mu sync.RWMutex
+	memoryData
+	// inTx хранилище — копия данных внутри Transact
+	inTx bool
+}
+
+// memoryData записи MemoryDataSource; Transact работает с их копией
+type memoryData struct {
+	teachers map[int]Teacher
+	students map[int]Student
+	courses  map[int]Course
+
+	// enrollments время записи по паре студент–курс
+	enrollments map[enrollmentKey]time.Time
+
+	users map[int]User
+	// userTokens токены учетных записей по хешу
+	userTokens map[string]memoryUserToken
+
+	nextTeacherID int
+	nextStudentID int
+	nextCourseID  int
+	nextUserID    int
+}
+
+type memoryUserToken struct {
+	UserToken
+	used bool
+}
+
+// NewMemoryDataSource создает новый экземпляр MemoryDataSource
+func NewMemoryDataSource() *MemoryDataSource {
+	return &MemoryDataSource{memoryData: memoryData{
+		teachers:      make(map[int]Teacher),
+		students:      make(map[int]Student),
+		courses:       make(map[int]Course),
+		enrollments:   make(map[enrollmentKey]time.Time),
+		users:         make(map[int]User),
+		userTokens:    make(map[string]memoryUserToken),
+		nextTeacherID: 1,
+		nextStudentID: 1,
+		nextCourseID:  1,
+		nextUserID:    1,
+	}}
+}
+
+// clone копирует данные; записи хранятся по значению, поэтому копии карт достаточно
+func (d memoryData) clone() memoryData {
+	d.teachers = maps.Clone(d.teachers)
+	d.students = maps.Clone(d.students)
+	d.courses = maps.Clone(d.courses)
+	d.enrollments = maps.Clone(d.enrollments)
+	d.users = maps.Clone(d.users)
+	d.userTokens = maps.Clone(d.userTokens)
+	return d
+}
+
+// Transact выполняет fn над копией данных под блокировкой записи и при успехе заменяет ею
+// текущие данные. Ошибка fn откатывает все изменения, а параллельные запросы не видят
+// промежуточного состояния. Транзакции выполняются по очереди, поэтому конфликтов
+// сериализации и повторов, как в PostgreSQL, не бывает
+func (ds *MemoryDataSource) Transact(ctx context.Context, fn func(tx Store) error) error {
+	if ds.inTx {
+		return fn(ds)
+	}
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	tx := &MemoryDataSource{memoryData: ds.memoryData.clone(), inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	// Как и в базе, отмененный запрос не фиксирует транзакцию
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ds.memoryData = tx.memoryData
+	return nil
+}
+
+// Ping всегда успешен: хранилище в памяти доступно, пока работает процесс
+func (ds *MemoryDataSource) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close ничего не делает: хранилищу в памяти нечего освобождать
+func (ds *MemoryDataSource) Close() error {
+	return nil
+}
+
+func (ds *MemoryDataSource) GetAllTeachers(ctx context.Context, params ListParams) (Page[Teacher], error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return paginate(sortedValues(ds.teachers), params, func(t Teacher) bool {
+		return hasPrefixFold(t.Name, params.Name) && hasPrefixFold(t.Email, params.Email)
+	}), nil
+}
+
+func (ds *MemoryDataSource) GetAllStudents(ctx context.Context, params ListParams) (Page[Student], error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return paginate(sortedValues(ds.students), params, func(s Student) bool {
+		return hasPrefixFold(s.Name, params.Name) && hasPrefixFold(s.Email, params.Email)
+	}), nil
+}
+
+func (ds *MemoryDataSource) GetAllCourses(ctx context.Context, params ListParams) (Page[Course], error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	return paginate(sortedValues(ds.courses), params, func(c Course) bool {
+		return hasPrefixFold(c.Title, params.Name)
+	}), nil
+}
+
+func (ds *MemoryDataSource) GetTeacherByID(ctx context.Context, id int) (Teacher, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	teacher, ok := ds.teachers[id]
+	if !ok {
+		return Teacher{}, ErrTeacherNotFound
+	}
+	return teacher, nil
+}
+
+func (ds *MemoryDataSource) GetStudentByID(ctx context.Context, id int) (Student, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	student, ok := ds.students[id]
+	if !ok {
+		return Student{}, ErrStudentNotFound
+	}
+	return student, nil
+}
+
+func (ds *MemoryDataSource) GetCourseByID(ctx context.Context, id int) (Course, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	course, ok := ds.courses[id]
+	if !ok {
+		return Course{}, ErrCourseNotFound
+	}
+	return course, nil
+}
+
+func (ds *MemoryDataSource) CreateTeacher(ctx context.Context, teacher Teacher) (Teacher, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for _, existing := range ds.teachers {
+		if existing.Email == teacher.Email {
+			return Teacher{}, emailTaken(teacher.Email)
+		}
+	}
+	teacher.ID, teacher.Version = ds.nextTeacherID, 1
+	ds.teachers[teacher.ID] = teacher
+	ds.nextTeacherID++
+	return teacher, nil
+}
+
+func (ds *MemoryDataSource) CreateStudent(ctx context.Context, student Student) (Student, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for _, existing := range ds.students {
+		if existing.Email == student.Email {
+			return Student{}, emailTaken(student.Email)
+		}
+	}
+	student.ID, student.Version = ds.nextStudentID, 1
+	ds.students[student.ID] = student
+	ds.nextStudentID++
+	return student, nil
+}
+
+func (ds *MemoryDataSource) CreateCourse(ctx context.Context, course Course) (Course, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if err := ds.checkTeacher(course.TeacherID); err != nil {
+		return Course{}, err
+	}
+	course.ID, course.Version = ds.nextCourseID, 1
+	ds.courses[course.ID] = course
+	ds.nextCourseID++
+	return course, nil
+}
+
+func (ds *MemoryDataSource) UpdateTeacher(ctx context.Context, teacher Teacher) (Teacher, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	return ds.updateTeacher(teacher)
+}
+
+func (ds *MemoryDataSource) ModifyTeacher(ctx context.Context, id int, modify func(Teacher) (Teacher, error)) (Teacher, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	current, ok := ds.teachers[id]
+	if !ok {
+		return Teacher{}, ErrTeacherNotFound
+	}
+	teacher, err := modify(current)
+	if err != nil {
+		return Teacher{}, err
+	}
+	teacher.ID, teacher.Version = id, current.Version
+	return ds.updateTeacher(teacher)
+}
+
+// updateTeacher записывает существующую запись, если ее версия совпадает с teacher.Version
+// (0 — любая), и увеличивает версию; вызывается под ds.mu
+func (ds *MemoryDataSource) updateTeacher(teacher Teacher) (Teacher, error) {
+	current, ok := ds.teachers[teacher.ID]
+	if !ok {
+		return Teacher{}, ErrTeacherNotFound
+	}
+	if err := checkVersion(teacher.Version, current.Version); err != nil {
+		return Teacher{}, err
+	}
+	for _, existing := range ds.teachers {
+		if existing.ID != teacher.ID && existing.Email == teacher.Email {
+			return Teacher{}, emailTaken(teacher.Email)
+		}
+	}
+	teacher.Version = current.Version + 1
+	ds.teachers[teacher.ID] = teacher
+	return teacher, nil
+}
+
+func (ds *MemoryDataSource) UpdateStudent(ctx context.Context, student Student) (Student, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	return ds.updateStudent(student)
+}
+
+func (ds *MemoryDataSource) ModifyStudent(ctx context.Context, id int, modify func(Student) (Student, error)) (Student, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	current, ok := ds.students[id]
+	if !ok {
+		return Student{}, ErrStudentNotFound
+	}
+	student, err := modify(current)
+	if err != nil {
+		return Student{}, err
+	}
+	student.ID, student.Version = id, current.Version
+	return ds.updateStudent(student)
+}
+
+// updateStudent записывает существующую запись с проверкой версии; вызывается под ds.mu
+func (ds *MemoryDataSource) updateStudent(student Student) (Student, error) {
+	current, ok := ds.students[student.ID]
+	if !ok {
+		return Student{}, ErrStudentNotFound
+	}
+	if err := checkVersion(student.Version, current.Version); err != nil {
+		return Student{}, err
+	}
+	for _, existing := range ds.students {
+		if existing.ID != student.ID && existing.Email == student.Email {
+			return Student{}, emailTaken(student.Email)
+		}
+	}
+	student.Version = current.Version + 1
+	ds.students[student.ID] = student
+	return student, nil
+}
+
+func (ds *MemoryDataSource) UpdateCourse(ctx context.Context, course Course) (Course, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	return ds.updateCourse(course)
+}
+
+func (ds *MemoryDataSource) ModifyCourse(ctx context.Context, id int, modify func(Course) (Course, error)) (Course, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	current, ok := ds.courses[id]
+	if !ok {
+		return Course{}, ErrCourseNotFound
+	}
+	course, err := modify(current)
+	if err != nil {
+		return Course{}, err
+	}
+	course.ID, course.Version = id, current.Version
+	return ds.updateCourse(course)
+}
+
+// updateCourse записывает существующую запись с проверкой версии; вызывается под ds.mu
+func (ds *MemoryDataSource) updateCourse(course Course) (Course, error) {
+	current, ok := ds.courses[course.ID]
+	if !ok {
+		return Course{}, ErrCourseNotFound
+	}
+	if err := checkVersion(course.Version, current.Version); err != nil {
+		return Course{}, err
+	}
+	if err := ds.checkTeacher(course.TeacherID); err != nil {
+		return Course{}, err
+	}
+	course.Version = current.Version + 1
+	ds.courses[course.ID] = course
+	return course, nil
+}
+
+func (ds *MemoryDataSource) DeleteTeacher(ctx context.Context, id, version int) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	teacher, ok := ds.teachers[id]
+	if !ok {
+		return ErrTeacherNotFound
+	}
+	if err := checkVersion(version, teacher.Version); err != nil {
+		return err
+	}
+	for _, course := range ds.courses {
+		if course.TeacherID == id {
+			return ErrTeacherHasCourses
+		}
+	}
+	delete(ds.teachers, id)
+	ds.deleteUsers(func(u User) bool { return u.TeacherID == id })
+	return nil
+}
+
+func (ds *MemoryDataSource) DeleteStudent(ctx context.Context, id, version int) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	student, ok := ds.students[id]
+	if !ok {
+		return ErrStudentNotFound
+	}
+	if err := checkVersion(version, student.Version); err != nil {
+		return err
+	}
+	delete(ds.students, id)
+	for key := range ds.enrollments {
+		if key.studentID == id {
+			delete(ds.enrollments, key)
+		}
+	}
+	ds.deleteUsers(func(u User) bool { return u.StudentID == id })
+	return nil
+}
+
+func (ds *MemoryDataSource) DeleteCourse(ctx context.Context, id, version int) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	course, ok := ds.courses[id]
+	if !ok {
+		return ErrCourseNotFound
+	}
+	if err := checkVersion(version, course.Version); err != nil {
+		return err
+	}
+	delete(ds.courses, id)
+	for key := range ds.enrollments {
+		if key.courseID == id {
+			delete(ds.enrollments, key)
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDataSource) ReassignCourses(ctx context.Context, fromTeacherID, toTeacherID int) (int, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if err := ds.checkTeacher(toTeacherID); err != nil {
+		return 0, err
+	}
+	reassigned := 0
+	for id, course := range ds.courses {
+		if course.TeacherID == fromTeacherID {
+			course.TeacherID = toTeacherID
+			course.Version++
+			ds.courses[id] = course
+			reassigned++
+		}
+	}
+	return reassigned, nil
+}
+
+func (ds *MemoryDataSource) EnrollStudent(ctx context.Context, studentID, courseID int) (Enrollment, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if _, ok := ds.students[studentID]; !ok {
+		return Enrollment{}, ErrStudentNotFound
+	}
+	if _, ok := ds.courses[courseID]; !ok {
+		return Enrollment{}, ErrCourseNotFound
+	}
+	key := enrollmentKey{studentID: studentID, courseID: courseID}
+	if _, ok := ds.enrollments[key]; ok {
+		return Enrollment{}, ErrAlreadyEnrolled
+	}
+
+	enrolledAt := time.Now()
+	ds.enrollments[key] = enrolledAt
+	return Enrollment{StudentID: studentID, CourseID: courseID, EnrolledAt: enrolledAt}, nil
+}
+
+func (ds *MemoryDataSource) UnenrollStudent(ctx context.Context, studentID, courseID int) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	key := enrollmentKey{studentID: studentID, courseID: courseID}
+	if _, ok := ds.enrollments[key]; !ok {
+		return ErrEnrollmentNotFound
+	}
+	delete(ds.enrollments, key)
+	return nil
+}
+
+func (ds *MemoryDataSource) GetCourseStudents(ctx context.Context, courseID int) ([]Student, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	if _, ok := ds.courses[courseID]; !ok {
+		return nil, ErrCourseNotFound
+	}
+	students := make(map[int]Student)
+	for key := range ds.enrollments {
+		if key.courseID == courseID {
+			students[key.studentID] = ds.students[key.studentID]
+		}
+	}
+	return sortedValues(students), nil
+}
+
+func (ds *MemoryDataSource) GetStudentCourses(ctx context.Context, studentID int) ([]Course, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	if _, ok := ds.students[studentID]; !ok {
+		return nil, ErrStudentNotFound
+	}
+	courses := make(map[int]Course)
+	for key := range ds.enrollments {
+		if key.studentID == studentID {
+			courses[key.courseID] = ds.courses[key.courseID]
+		}
+	}
+	return sortedValues(courses), nil
+}
+
+// checkTeacher повторяет проверку внешнего ключа courses.teacher_id
+func (ds *MemoryDataSource) checkTeacher(id int) error {
+	if id == 0 {
+		return nil
+	}
+	if _, ok := ds.teachers[id]; !ok {
+		return ErrUnknownTeacher
+	}
+	return nil
+}
+
+// sortedValues возвращает значения карты, упорядоченные по ID, как их вернул бы SELECT ... ORDER BY id
+func sortedValues[T any](m map[int]T) []T {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	res := make([]T, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, m[id])
+	}
+	return res
+}
+
+func (ds *MemoryDataSource) CreateUser(ctx context.Context, user User) (User, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for _, existing := range ds.users {
+		if existing.Username == user.Username ||
+			user.TeacherID != 0 && existing.TeacherID == user.TeacherID ||
+			user.StudentID != 0 && existing.StudentID == user.StudentID {
+			return User{}, ErrUserExists
+		}
+	}
+	if _, ok := ds.teachers[user.TeacherID]; user.TeacherID != 0 && !ok {
+		return User{}, ErrTeacherNotFound
+	}
+	if _, ok := ds.students[user.StudentID]; user.StudentID != 0 && !ok {
+		return User{}, ErrStudentNotFound
+	}
+	user.ID = ds.nextUserID
+	user.CreatedAt = time.Now()
+	ds.users[user.ID] = user
+	ds.nextUserID++
+	return user, nil
+}
+
+func (ds *MemoryDataSource) GetUserByID(ctx context.Context, id int) (User, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	user, ok := ds.users[id]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func (ds *MemoryDataSource) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	ds.mu.RLock()
+	defer ds.mu.RUnlock()
+
+	for _, user := range ds.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return User{}, ErrUserNotFound
+}
+
+func (ds *MemoryDataSource) SetPassword(ctx context.Context, userID int, passwordHash string) error {
+	return ds.updateUser(userID, func(user *User) {
+		user.PasswordHash = passwordHash
+		user.PasswordChangedAt = time.Now()
+		user.FailedLogins = 0
+		user.LockedUntil = time.Time{}
+	})
+}
+
+func (ds *MemoryDataSource) RecordLoginFailure(ctx context.Context, userID, maxFailures int, lockUntil time.Time) error {
+	return ds.updateUser(userID, func(user *User) {
+		user.FailedLogins++
+		if user.FailedLogins >= maxFailures {
+			user.FailedLogins = 0
+			user.LockedUntil = lockUntil
+		}
+	})
+}
+
+func (ds *MemoryDataSource) ResetLoginFailures(ctx context.Context, userID int) error {
+	return ds.updateUser(userID, func(user *User) {
+		user.FailedLogins = 0
+	})
+}
+
+func (ds *MemoryDataSource) RenameTeacherUser(ctx context.Context, teacherID int, username string) error {
+	return ds.renameUser(func(user User) bool { return user.TeacherID == teacherID }, username)
+}
+
+func (ds *MemoryDataSource) RenameStudentUser(ctx context.Context, studentID int, username string) error {
+	return ds.renameUser(func(user User) bool { return user.StudentID == studentID }, username)
+}
+
+// renameUser задает имя username учетной записи, выбранной match
+func (ds *MemoryDataSource) renameUser(match func(User) bool, username string) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for _, user := range ds.users {
+		if user.Username == username && !match(user) {
+			return ErrUserExists
+		}
+	}
+	for id, user := range ds.users {
+		if match(user) {
+			user.Username = username
+			ds.users[id] = user
+		}
+	}
+	return nil
+}
+
+func (ds *MemoryDataSource) CreateUserToken(ctx context.Context, token UserToken) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if _, ok := ds.users[token.UserID]; !ok {
+		return ErrUserNotFound
+	}
+	ds.userTokens[token.Hash] = memoryUserToken{UserToken: token}
+	return nil
+}
+
+func (ds *MemoryDataSource) ConsumeUserToken(ctx context.Context, hash, purpose string, now time.Time) (int, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	token, ok := ds.userTokens[hash]
+	if !ok || token.used || token.Purpose != purpose || !now.Before(token.ExpiresAt) {
+		return 0, ErrInvalidUserToken
+	}
+	token.used = true
+	ds.userTokens[hash] = token
+	return token.UserID, nil
+}
+
+// updateUser изменяет учетную запись под блокировкой записи
+func (ds *MemoryDataSource) updateUser(userID int, update func(user *User)) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	user, ok := ds.users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+	update(&user)
+	ds.users[userID] = user
+	return nil
+}
+
+// deleteUsers повторяет ON DELETE CASCADE для users и user_tokens; вызывается под блокировкой записи
+func (ds *MemoryDataSource) deleteUsers(match func(User) bool) {
+	for id, user := range ds.users {
+		if !match(user) {
+			continue
+		}
+		delete(ds.users, id)
+		for hash, token := range ds.userTokens {
+			if token.UserID == id {
+				delete(ds.userTokens, hash)
+			}
+		}
+	}
+}
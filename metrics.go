@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets границы гистограммы длительности запросов в секундах (как в клиентах Prometheus)
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// statsProvider хранилища с пулом соединений database/sql
+type statsProvider interface {
+	Stats() sql.DBStats
+}
+
+type requestKey struct {
+	route  string
+	method string
+	code   int
+}
+
+type routeKey struct {
+	route  string
+	method string
+}
+
+type interruptKey struct {
+	operation string
+	reason    string
+}
+
+// histogram накопительная гистограмма: counts[i] — число наблюдений не больше latencyBuckets[i]
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Metrics счетчики HTTP-запросов и пула соединений в текстовом формате Prometheus
+type Metrics struct {
+	dataSource DataSource
+	inFlight   atomic.Int64
+
+	mu        sync.Mutex
+	requests  map[requestKey]uint64
+	durations map[routeKey]*histogram
+	// interrupted операции с хранилищем, прерванные таймаутом или отменой запроса
+	interrupted map[interruptKey]uint64
+}
+
+// NewMetrics создает новый экземпляр Metrics; статистика пула берется из dataSource, если он ее отдает
+func NewMetrics(dataSource DataSource) *Metrics {
+	return &Metrics{
+		dataSource:  dataSource,
+		requests:    make(map[requestKey]uint64),
+		durations:   make(map[routeKey]*histogram),
+		interrupted: make(map[interruptKey]uint64),
+	}
+}
+
+// statusRecorder запоминает код ответа обработчика
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Instrument оборачивает обработчик маршрута pattern ("GET /teachers/{id}") подсчетом запросов.
+// Метки используют шаблон маршрута, а не фактический путь, чтобы число рядов не зависело от ID
+func (m *Metrics) Instrument(pattern string, handler http.Handler) http.Handler {
+	method, route, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, route = "", pattern
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Add(1)
+		defer m.inFlight.Add(-1)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+
+		requestMethod := method
+		if requestMethod == "" {
+			requestMethod = r.Method
+		}
+		m.observe(route, requestMethod, recorder.code, time.Since(start))
+	})
+}
+
+func (m *Metrics) observe(route, method string, code int, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[requestKey{route: route, method: method, code: code}]++
+
+	key := routeKey{route: route, method: method}
+	h, ok := m.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.durations[key] = h
+	}
+	seconds := elapsed.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// ObserveInterrupted учитывает операцию с хранилищем, прерванную по причине reason:
+// timeout — истек таймаут db.timeouts, canceled — клиент закрыл соединение
+func (m *Metrics) ObserveInterrupted(operation, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.interrupted[interruptKey{operation: operation, reason: reason}]++
+}
+
+// Handler отдает метрики в текстовом формате Prometheus 0.0.4
+func (m *Metrics) Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	defer out.Flush()
+
+	m.mu.Lock()
+	requestKeys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requestKeys = append(requestKeys, key)
+	}
+	sort.Slice(requestKeys, func(i, j int) bool {
+		a, b := requestKeys[i], requestKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	fmt.Fprintln(out, "# HELP http_requests_total Total number of HTTP requests by route, method and status code.")
+	fmt.Fprintln(out, "# TYPE http_requests_total counter")
+	for _, key := range requestKeys {
+		fmt.Fprintf(out, "http_requests_total{route=%s,method=%s,code=\"%d\"} %d\n",
+			quoteLabel(key.route), quoteLabel(key.method), key.code, m.requests[key])
+	}
+
+	routeKeys := make([]routeKey, 0, len(m.durations))
+	for key := range m.durations {
+		routeKeys = append(routeKeys, key)
+	}
+	sort.Slice(routeKeys, func(i, j int) bool {
+		a, b := routeKeys[i], routeKeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		return a.method < b.method
+	})
+	fmt.Fprintln(out, "# HELP http_request_duration_seconds HTTP request latency by route and method.")
+	fmt.Fprintln(out, "# TYPE http_request_duration_seconds histogram")
+	for _, key := range routeKeys {
+		h := m.durations[key]
+		labels := fmt.Sprintf("route=%s,method=%s", quoteLabel(key.route), quoteLabel(key.method))
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(out, "http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(out, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(out, "http_request_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(out, "http_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+
+	interruptKeys := make([]interruptKey, 0, len(m.interrupted))
+	for key := range m.interrupted {
+		interruptKeys = append(interruptKeys, key)
+	}
+	sort.Slice(interruptKeys, func(i, j int) bool {
+		a, b := interruptKeys[i], interruptKeys[j]
+		if a.operation != b.operation {
+			return a.operation < b.operation
+		}
+		return a.reason < b.reason
+	})
+	fmt.Fprintln(out, "# HELP storage_operations_interrupted_total Storage operations aborted by a timeout or a canceled request.")
+	fmt.Fprintln(out, "# TYPE storage_operations_interrupted_total counter")
+	for _, key := range interruptKeys {
+		fmt.Fprintf(out, "storage_operations_interrupted_total{operation=%s,reason=%s} %d\n",
+			quoteLabel(key.operation), quoteLabel(key.reason), m.interrupted[key])
+	}
+	m.mu.Unlock()
+
+	fmt.Fprintln(out, "# HELP http_requests_in_flight Number of HTTP requests currently being served.")
+	fmt.Fprintln(out, "# TYPE http_requests_in_flight gauge")
+	fmt.Fprintf(out, "http_requests_in_flight %d\n", m.inFlight.Load())
+
+	if provider, ok := m.dataSource.(statsProvider); ok {
+		writeDBStats(out, provider.Stats())
+	}
+}
+
+// writeDBStats выводит статистику пула соединений sql.DB
+func writeDBStats(out *bufio.Writer, stats sql.DBStats) {
+	metric := func(name, kind, help string, value interface{}) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("db_max_open_connections", "gauge", "Maximum number of open connections to the database.", stats.MaxOpenConnections)
+	metric("db_open_connections", "gauge", "Number of established connections, both in use and idle.", stats.OpenConnections)
+	metric("db_in_use_connections", "gauge", "Number of connections currently in use.", stats.InUse)
+	metric("db_idle_connections", "gauge", "Number of idle connections.", stats.Idle)
+	metric("db_wait_count_total", "counter", "Total number of connections waited for.", stats.WaitCount)
+	metric("db_wait_duration_seconds_total", "counter", "Total time blocked waiting for a new connection.", stats.WaitDuration.Seconds())
+	metric("db_max_idle_closed_total", "counter", "Connections closed due to SetMaxIdleConns.", stats.MaxIdleClosed)
+	metric("db_max_idle_time_closed_total", "counter", "Connections closed due to SetConnMaxIdleTime.", stats.MaxIdleTimeClosed)
+	metric("db_max_lifetime_closed_total", "counter", "Connections closed due to SetConnMaxLifetime.", stats.MaxLifetimeClosed)
+}
+
+// quoteLabel экранирует значение метки по правилам текстового формата Prometheus
+func quoteLabel(value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return `"` + value + `"`
+}
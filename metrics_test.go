@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMetricsUseRoutePatterns(t *testing.T) {
+	api := newTestAPI(t, NewMemoryDataSource())
+	teacher := mustCreateTeacher(t, api.service, "Иван Петрович", "ivan@example.com")
+	api.do(http.MethodGet, "/teachers/"+strconv.Itoa(teacher.ID), api.adminToken, "")
+	api.do(http.MethodGet, "/teachers/999", api.adminToken, "")
+	api.do(http.MethodGet, "/teachers/999", "", "")
+
+	w := api.do(http.MethodGet, "/metrics", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	body := w.Body.String()
+	// ID из пути не попадает в метки, иначе число рядов растет с числом записей
+	for _, want := range []string{
+		`http_requests_total{route="/teachers/{id}",method="GET",code="200"} 1`,
+		`http_requests_total{route="/teachers/{id}",method="GET",code="404"} 1`,
+		`http_requests_total{route="/teachers/{id}",method="GET",code="401"} 1`,
+		`http_request_duration_seconds_count{route="/teachers/{id}",method="GET"} 3`,
+		"http_requests_in_flight 0",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	if strings.Contains(body, "/teachers/999") {
+		t.Error("metrics contain a concrete path")
+	}
+}
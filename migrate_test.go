@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// sqliteTables возвращает таблицы базы, кроме служебных таблиц SQLite
+func sqliteTables(t *testing.T, m *Migrator) []string {
+	t.Helper()
+	rows, err := m.db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, name)
+	}
+	return tables
+}
+
+// appliedVersions возвращает версии, которые Status считает примененными
+func appliedVersions(t *testing.T, m *Migrator) []int {
+	t.Helper()
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, s := range statuses {
+		if s.Applied {
+			versions = append(versions, s.Version)
+		}
+	}
+	return versions
+}
+
+func TestMigrationsRoundTripOnSQLite(t *testing.T) {
+	db, err := openSQLite(DBConfig{DSN: "sqlite://" + filepath.Join(t.TempDir(), "migrate.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	m, err := NewMigrator(db, sqliteDialect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := make([]int, len(m.migrations))
+	for i, migration := range m.migrations {
+		all[i] = migration.Version
+		if migration.Down == "" {
+			t.Errorf("migration %04d_%s has no down script", migration.Version, migration.Name)
+		}
+	}
+
+	if got := appliedVersions(t, m); got != nil {
+		t.Fatalf("fresh database: applied = %v", got)
+	}
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, m); !reflect.DeepEqual(got, all) {
+		t.Fatalf("after up: applied = %v, want %v", got, all)
+	}
+	if pending, err := m.Pending(context.Background()); err != nil || len(pending) != 0 {
+		t.Fatalf("after up: pending = %v, %v", pending, err)
+	}
+	schema := sqliteTables(t, m)
+	// Повторный Up ничего не делает
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Down(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, m); !reflect.DeepEqual(got, all[:len(all)-1]) {
+		t.Fatalf("after down 1: applied = %v", got)
+	}
+	if err := m.Down(len(all)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sqliteTables(t, m); !reflect.DeepEqual(got, []string{"schema_migrations"}) {
+		t.Fatalf("after full down: tables = %v", got)
+	}
+
+	// Down-скрипты откатывают схему полностью, поэтому миграции применяются заново
+	if err := m.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if got := sqliteTables(t, m); !reflect.DeepEqual(got, schema) {
+		t.Fatalf("after second up: tables = %v, want %v", got, schema)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []int
+		wantErr string
+	}{
+		{"sorted by version", fstest.MapFS{
+			"m/0002_b.up.sql":   {Data: []byte("B")},
+			"m/0001_a.up.sql":   {Data: []byte("A")},
+			"m/0001_a.down.sql": {Data: []byte("-A")},
+			"m/README.md":       {Data: []byte("ignored")},
+		}, []int{1, 2}, ""},
+		{"missing up", fstest.MapFS{"m/0001_a.down.sql": {Data: []byte("-A")}}, nil, "missing up script"},
+		{"bad version", fstest.MapFS{"m/first_a.up.sql": {Data: []byte("A")}}, nil, "invalid version"},
+		{"no name", fstest.MapFS{"m/0001.up.sql": {Data: []byte("A")}}, nil, "expected <version>_<name>"},
+		{"conflicting names", fstest.MapFS{
+			"m/0001_a.up.sql":   {Data: []byte("A")},
+			"m/0001_b.down.sql": {Data: []byte("-B")},
+		}, nil, "conflicting names"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := loadMigrations(tt.files, "m")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, m := range migrations {
+				got = append(got, m.Version)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("versions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrationsMatchAcrossDialects(t *testing.T) {
+	postgres, err := loadMigrations(migrationFiles, postgresDialect.migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := loadMigrations(migrationFiles, sqliteDialect.migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(migrations []Migration) []string {
+		var names []string
+		for _, m := range migrations {
+			names = append(names, m.Name)
+		}
+		return names
+	}
+	if !reflect.DeepEqual(names(postgres), names(sqlite)) {
+		t.Fatalf("postgres %v and sqlite %v migrations differ", names(postgres), names(sqlite))
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE courses DROP COLUMN price;
+ALTER TABLE courses RENAME COLUMN title TO name;
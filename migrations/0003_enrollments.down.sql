@@ -0,0 +1,6 @@
+ALTER TABLE students ADD COLUMN course_id INT REFERENCES courses(id);
+
+UPDATE students s
+SET course_id = (SELECT MIN(e.course_id) FROM enrollments e WHERE e.student_id = s.id);
+
+DROP TABLE enrollments;
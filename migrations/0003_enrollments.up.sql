@@ -0,0 +1,13 @@
+CREATE TABLE enrollments (
+    student_id INT NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    course_id INT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    enrolled_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (student_id, course_id)
+);
+
+CREATE INDEX enrollments_course_id_idx ON enrollments (course_id);
+
+INSERT INTO enrollments (student_id, course_id)
+SELECT id, course_id FROM students WHERE course_id IS NOT NULL;
+
+ALTER TABLE students DROP COLUMN course_id;
@@ -0,0 +1,2 @@
+ALTER TABLE courses RENAME COLUMN name TO title;
+ALTER TABLE courses ADD COLUMN price NUMERIC(10, 2) NOT NULL DEFAULT 0 CHECK (price >= 0);
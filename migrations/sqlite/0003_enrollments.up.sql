@@ -0,0 +1,8 @@
+CREATE TABLE enrollments (
+    student_id INT NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    course_id INT NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    enrolled_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (student_id, course_id)
+);
+
+CREATE INDEX enrollments_course_id_idx ON enrollments (course_id);
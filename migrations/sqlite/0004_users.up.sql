@@ -0,0 +1,25 @@
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username VARCHAR(255) NOT NULL UNIQUE,
+    -- NULL, пока пользователь не принял приглашение
+    password_hash VARCHAR(255),
+    role VARCHAR(16) NOT NULL CHECK (role IN ('admin', 'teacher', 'student')),
+    teacher_id INT UNIQUE REFERENCES teachers(id) ON DELETE CASCADE,
+    student_id INT UNIQUE REFERENCES students(id) ON DELETE CASCADE,
+    failed_logins INT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (role <> 'teacher' OR teacher_id IS NOT NULL),
+    CHECK (role <> 'student' OR student_id IS NOT NULL)
+);
+
+-- Одноразовые токены приглашений и сброса пароля; хранится только SHA-256 токена
+CREATE TABLE user_tokens (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(16) NOT NULL CHECK (purpose IN ('invite', 'reset')),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX user_tokens_user_id_idx ON user_tokens (user_id);
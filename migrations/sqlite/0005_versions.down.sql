@@ -0,0 +1,3 @@
+ALTER TABLE courses DROP COLUMN version;
+ALTER TABLE students DROP COLUMN version;
+ALTER TABLE teachers DROP COLUMN version;
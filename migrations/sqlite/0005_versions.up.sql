@@ -0,0 +1,4 @@
+-- Версия записи для оптимистической блокировки: каждое изменение увеличивает ее на 1
+ALTER TABLE teachers ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE students ADD COLUMN version INT NOT NULL DEFAULT 1;
+ALTER TABLE courses ADD COLUMN version INT NOT NULL DEFAULT 1;
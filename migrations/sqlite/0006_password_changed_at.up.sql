@@ -0,0 +1,2 @@
+-- Время последней смены пароля: refresh-токены, выданные раньше, больше не принимаются
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP;
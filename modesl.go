@@ -1,23 +1,63 @@
 package main
 
+import "time"
+
 // Teacher модель преподавателя
 type Teacher struct {
 	ID    int    `json:"id"`
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	// Version увеличивается при каждом изменении; передается клиенту в заголовке ETag
+	Version int `json:"-"`
 }
 
 // Course модель курса
 type Course struct {
-	ID        int     `json:"id"`
-	Title     string  `json:"title"`
-	TeacherID int     `json:"teacher_id"`
-	Price     float64 `json:"price"`
+	ID          int     `json:"id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	TeacherID   int     `json:"teacher_id"`
+	Price       float64 `json:"price"`
+	Version     int     `json:"-"`
 }
 
 // Student модель студента
 type Student struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Version int    `json:"-"`
+}
+
+// Enrollment запись студента на курс
+type Enrollment struct {
+	StudentID  int       `json:"student_id"`
+	CourseID   int       `json:"course_id"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+}
+
+// User учетная запись для входа. Преподаватели и студенты связаны со своей записью
+// через TeacherID или StudentID
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	// PasswordHash хеш bcrypt; пустой, пока приглашение не принято
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	TeacherID    int       `json:"teacher_id,omitempty"`
+	StudentID    int       `json:"student_id,omitempty"`
+	FailedLogins int       `json:"-"`
+	LockedUntil  time.Time `json:"-"`
+	// PasswordChangedAt время последней смены пароля; refresh-токены, выданные раньше, недействительны
+	PasswordChangedAt time.Time `json:"-"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// UserToken одноразовый токен приглашения или сброса пароля
+type UserToken struct {
+	// Hash SHA-256 токена в hex; сам токен отправляется только в письме
+	Hash      string
+	UserID    int
+	Purpose   string
+	ExpiresAt time.Time
 }
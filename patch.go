@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// MergePatch тело запроса PATCH в формате JSON Merge Patch (RFC 7396):
+// поля из патча заменяют поля записи, null удаляет поле, то есть сбрасывает его в нулевое значение
+type MergePatch map[string]interface{}
+
+// apply применяет патч к target и записывает результат в out.
+// Результат разбирается так же строго, как тело POST и PUT, поэтому неизвестные поля
+// и значения неверного типа возвращаются как ошибки полей
+func (p MergePatch) apply(target, out interface{}) error {
+	data, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Числа сохраняются как есть, без потери точности через float64
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+
+	merged, err := json.Marshal(mergeValue(doc, map[string]interface{}(p)))
+	if err != nil {
+		return err
+	}
+	return decodeStrict(bytes.NewReader(merged), out)
+}
+
+// mergeValue реализует алгоритм MergePatch из RFC 7396
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergeValue(targetObject[name], value)
+	}
+	return targetObject
+}
+
+// decodeMergePatch читает MergePatch из тела запроса; патч должен быть JSON-объектом
+func decodeMergePatch(r *http.Request) (MergePatch, error) {
+	var patch MergePatch
+	if err := decodeJSON(r, &patch); err != nil {
+		return nil, err
+	}
+	if patch == nil {
+		return nil, invalidJSON("merge patch must be a JSON object")
+	}
+	return patch, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestPatchCourse(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		api := newTestAPI(t, ds)
+		teacher := mustCreateTeacher(t, api.service, "Иван Петрович", "ivan@example.com")
+		course, err := api.service.CreateCourse(context.Background(),
+			Course{Title: "Go", Description: "Основы", TeacherID: teacher.ID, Price: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		target := "/courses/" + strconv.Itoa(course.ID)
+
+		tests := []struct {
+			name       string
+			body       string
+			wantStatus int
+			want       Course
+		}{
+			{"replaces given fields", `{"title":"Go 2"}`, http.StatusOK,
+				Course{Title: "Go 2", Description: "Основы", Price: 10}},
+			{"null resets a field", `{"description":null,"price":20}`, http.StatusOK,
+				Course{Title: "Go 2", Price: 20}},
+			{"id is ignored", `{"id":100}`, http.StatusOK, Course{Title: "Go 2", Price: 20}},
+			{"result is validated", `{"title":""}`, http.StatusUnprocessableEntity, Course{}},
+			{"wrong type", `{"price":"free"}`, http.StatusUnprocessableEntity, Course{}},
+			{"not an object", `[1]`, http.StatusBadRequest, Course{}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := api.do(http.MethodPatch, target, api.adminToken, tt.body, "If-Match", "*")
+				if w.Code != tt.wantStatus {
+					t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+				}
+				if tt.wantStatus != http.StatusOK {
+					return
+				}
+				var got Course
+				decodeBody(t, w, &got)
+				if got.Title != tt.want.Title || got.Description != tt.want.Description || got.Price != tt.want.Price ||
+					got.ID != course.ID || got.TeacherID != teacher.ID {
+					t.Fatalf("course = %+v", got)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+// Роли пользователей
+const (
+	RoleAdmin   = "admin"
+	RoleTeacher = "teacher"
+	RoleStudent = "student"
+)
+
+// Action действие над ресурсом, которое проверяет Policy
+type Action string
+
+const (
+	ActionListTeachers  Action = "teachers.list"
+	ActionReadTeacher   Action = "teachers.read"
+	ActionCreateTeacher Action = "teachers.create"
+	ActionUpdateTeacher Action = "teachers.update"
+	ActionDeleteTeacher Action = "teachers.delete"
+
+	ActionListStudents       Action = "students.list"
+	ActionReadStudent        Action = "students.read"
+	ActionCreateStudent      Action = "students.create"
+	ActionUpdateStudent      Action = "students.update"
+	ActionDeleteStudent      Action = "students.delete"
+	ActionListStudentCourses Action = "students.courses"
+
+	ActionListCourses        Action = "courses.list"
+	ActionReadCourse         Action = "courses.read"
+	ActionCreateCourse       Action = "courses.create"
+	ActionUpdateCourse       Action = "courses.update"
+	ActionDeleteCourse       Action = "courses.delete"
+	ActionListCourseStudents Action = "courses.students"
+
+	ActionEnroll   Action = "enrollments.create"
+	ActionUnenroll Action = "enrollments.delete"
+
+	ActionInviteUser Action = "users.invite"
+)
+
+// Resource владельцы записи, к которой относится действие; нулевое значение — не задан
+type Resource struct {
+	// TeacherID преподаватель, за которым закреплен курс
+	TeacherID int
+	// StudentID студент, чей профиль затрагивается
+	StudentID int
+}
+
+// Policy решает, может ли principal выполнить action над resource
+type Policy interface {
+	Authorize(principal Principal, action Action, resource Resource) error
+}
+
+// rule разрешает действие ролям, кроме администратора, которому разрешено все
+type rule func(principal Principal, resource Resource) bool
+
+// RolePolicy правила доступа по ролям:
+// администратор управляет всем; преподаватель читает каталог, редактирует свои курсы
+// и видит записанных на них студентов; студент читает каталог и свой профиль
+type RolePolicy struct{}
+
+var rolePolicyRules = map[Action]rule{
+	ActionListTeachers: anyRole,
+	ActionReadTeacher:  anyRole,
+	ActionListCourses:  anyRole,
+	ActionReadCourse:   anyRole,
+
+	ActionReadStudent:        isStudent,
+	ActionListStudentCourses: isStudent,
+
+	ActionUpdateCourse:       teachesCourse,
+	ActionListCourseStudents: teachesCourse,
+}
+
+func (RolePolicy) Authorize(principal Principal, action Action, resource Resource) error {
+	if principal.Role == RoleAdmin {
+		return nil
+	}
+	if allowed, ok := rolePolicyRules[action]; ok && allowed(principal, resource) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func anyRole(principal Principal, resource Resource) bool {
+	return principal.Role == RoleTeacher || principal.Role == RoleStudent
+}
+
+// isStudent разрешает студенту доступ только к собственному профилю
+func isStudent(principal Principal, resource Resource) bool {
+	return principal.Role == RoleStudent && principal.EntityID != 0 && principal.EntityID == resource.StudentID
+}
+
+// teachesCourse разрешает преподавателю доступ только к курсам, закрепленным за ним
+func teachesCourse(principal Principal, resource Resource) bool {
+	return principal.Role == RoleTeacher && principal.EntityID != 0 && principal.EntityID == resource.TeacherID
+}
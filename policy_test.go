@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestAccessPolicy(t *testing.T) {
+	api := newTestAPI(t, NewMemoryDataSource())
+	ctx := context.Background()
+	s := api.service
+	own := mustCreateTeacher(t, s, "Иван Петрович", "ivan@example.com")
+	other := mustCreateTeacher(t, s, "Мария Сергеевна", "maria@example.com")
+	course, err := s.CreateCourse(ctx, Course{Title: "Go", TeacherID: own.ID, Price: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCourse, err := s.CreateCourse(ctx, Course{Title: "SQL", TeacherID: other.ID, Price: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	student := mustCreateStudent(t, s, "Анна", "anna@example.com")
+	classmate := mustCreateStudent(t, s, "Олег", "oleg@example.com")
+
+	teacherToken := api.token(t, Principal{Subject: "ivan", Role: RoleTeacher, EntityID: own.ID})
+	studentToken := api.token(t, Principal{Subject: "anna", Role: RoleStudent, EntityID: student.ID})
+	itoa := strconv.Itoa
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{"no token", http.MethodGet, "/teachers", "", "", http.StatusUnauthorized},
+		{"malformed token", http.MethodGet, "/teachers", "not-a-token", "", http.StatusUnauthorized},
+		{"student reads catalog", http.MethodGet, "/courses", studentToken, "", http.StatusOK},
+		{"student reads own profile", http.MethodGet, "/students/" + itoa(student.ID), studentToken, "", http.StatusOK},
+		{"student reads another profile", http.MethodGet, "/students/" + itoa(classmate.ID), studentToken, "", http.StatusForbidden},
+		{"student lists students", http.MethodGet, "/students", studentToken, "", http.StatusForbidden},
+		{"student creates teacher", http.MethodPost, "/teachers", studentToken,
+			`{"name":"Петр","email":"petr@example.com"}`, http.StatusForbidden},
+		{"student deletes teacher", http.MethodDelete, "/teachers/" + itoa(other.ID), studentToken, "", http.StatusForbidden},
+		{"teacher edits own course", http.MethodPatch, "/courses/" + itoa(course.ID), teacherToken,
+			`{"title":"Go 2"}`, http.StatusOK},
+		{"teacher hands course over", http.MethodPatch, "/courses/" + itoa(course.ID), teacherToken,
+			`{"teacher_id":` + itoa(other.ID) + `}`, http.StatusForbidden},
+		{"teacher edits another course", http.MethodPatch, "/courses/" + itoa(otherCourse.ID), teacherToken,
+			`{"title":"Мой SQL"}`, http.StatusForbidden},
+		{"teacher lists own course students", http.MethodGet, "/courses/" + itoa(course.ID) + "/students", teacherToken, "", http.StatusOK},
+		{"teacher invites user", http.MethodPost, "/accounts/invitations", teacherToken,
+			`{"role":"teacher","teacher_id":` + itoa(other.ID) + `}`, http.StatusForbidden},
+		{"admin deletes teacher", http.MethodDelete, "/teachers/" + itoa(other.ID), api.adminToken, "", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := api.do(tt.method, tt.target, tt.token, tt.body, "If-Match", "*")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+}
+
+func TestUpdateCourseChecksCurrentOwner(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		api := newTestAPI(t, ds)
+		ctx := context.Background()
+		former := mustCreateTeacher(t, api.service, "Иван Петрович", "ivan@example.com")
+		successor := mustCreateTeacher(t, api.service, "Мария Сергеевна", "maria@example.com")
+		course, err := api.service.CreateCourse(ctx, Course{Title: "Go", TeacherID: former.ID, Price: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		formerToken := api.token(t, Principal{Subject: "ivan", Role: RoleTeacher, EntityID: former.ID})
+		body := `{"title":"Go 2","teacher_id":` + strconv.Itoa(former.ID) + `,"price":10}`
+
+		// Курс передали, пока бывший владелец держал его открытым; If-Match: * версию не проверяет
+		if w := api.do(http.MethodPatch, "/courses/"+strconv.Itoa(course.ID), api.adminToken,
+			`{"teacher_id":`+strconv.Itoa(successor.ID)+`}`, "If-Match", "*"); w.Code != http.StatusOK {
+			t.Fatalf("reassign: status = %d: %s", w.Code, w.Body)
+		}
+		w := api.do(http.MethodPut, "/courses/"+strconv.Itoa(course.ID), formerToken, body, "If-Match", "*")
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("PUT by former owner: status = %d, want 403: %s", w.Code, w.Body)
+		}
+		if got, err := api.service.GetCourseByID(ctx, course.ID); err != nil || got.TeacherID != successor.ID || got.Title != "Go" {
+			t.Fatalf("course = %+v, %v", got, err)
+		}
+
+		// Чужой владелец в теле отклоняется до обращения к хранилищу, есть курс или нет
+		other := `{"title":"Go","teacher_id":` + strconv.Itoa(successor.ID) + `,"price":10}`
+		for _, target := range []string{"/courses/" + strconv.Itoa(course.ID), "/courses/999"} {
+			if w := api.do(http.MethodPut, target, formerToken, other, "If-Match", "*"); w.Code != http.StatusForbidden {
+				t.Fatalf("PUT %s: status = %d, want 403: %s", target, w.Code, w.Body)
+			}
+		}
+	})
+}
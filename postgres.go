@@ -0,0 +1,82 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// postgresDialect синтаксис PostgreSQL
+var postgresDialect = dialect{
+	driver:           "postgres",
+	fold:             "lower",
+	collate:          `COLLATE "C"`,
+	lockRow:          "FOR UPDATE",
+	timestamp:        "TIMESTAMPTZ",
+	isolation:        sql.LevelSerializable,
+	migrations:       "migrations",
+	lockMigrations:   "SELECT pg_advisory_lock($1)",
+	unlockMigrations: "SELECT pg_advisory_unlock($1)",
+}
+
+// PostgresDataSource хранилище на PostgreSQL
+type PostgresDataSource struct {
+	*sqlDataSource
+}
+
+// NewPostgresDataSource создает новый экземпляр PostgresDataSource с подключением к PostgreSQL
+// и применяет непримененные миграции схемы
+func NewPostgresDataSource(cfg DBConfig) (*PostgresDataSource, error) {
+	db, err := openPostgres(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := newSQLDataSource(db, postgresDialect)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresDataSource{ds}, nil
+}
+
+// openPostgres открывает пул соединений с настройками из DBConfig
+func openPostgres(cfg DBConfig) (*sql.DB, error) {
+	db, err := sql.Open(postgresDialect.driver, cfg.URL())
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime.Duration)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime.Duration)
+	return db, nil
+}
+
+// isPostgresUnavailable сообщает, что сервер PostgreSQL недоступен или перегружен:
+// класс 08 (ошибки соединения), остановка сервера и превышение лимита соединений
+func isPostgresUnavailable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code.Class() {
+	case "08":
+		return true
+	}
+	switch pqErr.Code {
+	case "57P01", "57P02", "57P03", "53300":
+		return true
+	}
+	return false
+}
+
+// isSerializationFailure сообщает, что PostgreSQL прервал транзакцию из-за конфликта
+// с параллельной (40001) или взаимной блокировки (40P01) и ее можно повторить.
+// В SQLite транзакции записи выполняются по очереди, и таких ошибок нет
+func isSerializationFailure(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// legacyMethods методы, на которых обслуживаются устаревшие пути вида /teachers/create.
+// Раньше они принимали любой метод, но пути изменяют данные, поэтому GET не регистрируется:
+// предзагрузка ссылок и поисковые роботы не должны создавать и удалять записи.
+// GET на таком пути попадает в GET /teachers/{id} и получает 400
+var legacyMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// newRouter регистрирует маршруты API. Неподдерживаемый метод на известном пути
+// ServeMux отклоняет сам: 405 с заголовком Allow.
+// Каждый маршрут оборачивается метриками с шаблоном маршрута в качестве метки.
+// Маршруты API, включая устаревшие, регистрируются через protect и требуют access-токен;
+// что именно разрешено пользователю, решает Policy в обработчиках Controller.
+// Токен нужен и на чтении: политика ролей ограничивает и его (студент видит только свой профиль,
+// преподаватель — студентов своих курсов), а без токена пользователь неизвестен
+func newRouter(controller *Controller, health *Health, metrics *Metrics, auth *Auth) *http.ServeMux {
+	mux := http.NewServeMux()
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, metrics.Instrument(pattern, handler))
+	}
+	protect := func(pattern string, handler http.HandlerFunc) {
+		handle(pattern, auth.Require(handler))
+	}
+
+	protect("GET /teachers", controller.GetAllTeachersHandler)
+	protect("POST /teachers", controller.CreateTeacherHandler)
+	protect("GET /teachers/{id}", controller.GetTeacherHandler)
+	protect("PUT /teachers/{id}", controller.UpdateTeacherHandler)
+	protect("PATCH /teachers/{id}", controller.PatchTeacherHandler)
+	protect("DELETE /teachers/{id}", controller.DeleteTeacherHandler)
+
+	protect("GET /students", controller.GetAllStudentsHandler)
+	protect("POST /students", controller.CreateStudentHandler)
+	protect("GET /students/{id}", controller.GetStudentHandler)
+	protect("PUT /students/{id}", controller.UpdateStudentHandler)
+	protect("PATCH /students/{id}", controller.PatchStudentHandler)
+	protect("DELETE /students/{id}", controller.DeleteStudentHandler)
+
+	protect("GET /courses", controller.GetAllCoursesHandler)
+	protect("POST /courses", controller.CreateCourseHandler)
+	protect("GET /courses/{id}", controller.GetCourseHandler)
+	protect("PUT /courses/{id}", controller.UpdateCourseHandler)
+	protect("PATCH /courses/{id}", controller.PatchCourseHandler)
+	protect("DELETE /courses/{id}", controller.DeleteCourseHandler)
+
+	// Запись студентов на курсы
+	protect("GET /courses/{id}/students", controller.GetCourseStudentsHandler)
+	protect("POST /courses/{id}/students", controller.EnrollStudentHandler)
+	protect("DELETE /courses/{id}/students/{student_id}", controller.UnenrollStudentHandler)
+	protect("GET /students/{id}/courses", controller.GetStudentCoursesHandler)
+
+	// Устаревшие пути для существующих клиентов
+	handleLegacy(protect, "/teachers/create", "/teachers", controller.CreateTeacherHandler)
+	handleLegacy(protect, "/teachers/update", "/teachers/{id}", idFromBody(controller.UpdateTeacherHandler))
+	handleLegacy(protect, "/teachers/delete", "/teachers/{id}", idFromBody(controller.DeleteTeacherHandler))
+
+	handleLegacy(protect, "/students/create", "/students", controller.CreateStudentHandler)
+	handleLegacy(protect, "/students/update", "/students/{id}", idFromBody(controller.UpdateStudentHandler))
+	handleLegacy(protect, "/students/delete", "/students/{id}", idFromQuery(controller.DeleteStudentHandler))
+
+	handleLegacy(protect, "/courses/create", "/courses", controller.CreateCourseHandler)
+	handleLegacy(protect, "/courses/update", "/courses/{id}", idFromBody(controller.UpdateCourseHandler))
+	handleLegacy(protect, "/courses/delete", "/courses/{id}", idFromBody(controller.DeleteCourseHandler))
+
+	// Выдача и отзыв токенов
+	handle("POST /auth/login", auth.LoginHandler)
+	handle("POST /auth/refresh", auth.RefreshHandler)
+	protect("POST /auth/logout", auth.LogoutHandler)
+
+	// Учетные записи: приглашения и сброс пароля
+	protect("POST /accounts/invitations", controller.InviteUserHandler)
+	handle("POST /accounts/invitations/accept", controller.AcceptInvitationHandler)
+	handle("POST /accounts/password-reset", controller.RequestPasswordResetHandler)
+	handle("POST /accounts/password-reset/confirm", controller.ResetPasswordHandler)
+
+	// Проверки живости и готовности; /health оставлен для старых клиентов и проверяет готовность
+	handle("GET /health/live", health.LiveHandler)
+	handle("GET /health/ready", health.ReadyHandler)
+	handle("GET /health", health.ReadyHandler)
+
+	mux.HandleFunc("GET /metrics", metrics.Handler)
+
+	return mux
+}
+
+// handleLegacy регистрирует устаревший путь и помечает ответы заголовками Deprecation и Link на замену
+func handleLegacy(handle func(pattern string, handler http.HandlerFunc), path, successor string, handler http.HandlerFunc) {
+	deprecated := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		handler(w, r)
+	}
+	for _, method := range legacyMethods {
+		handle(method+" "+path, deprecated)
+	}
+}
+
+// idFromBody переносит поле id из JSON-тела в параметр пути {id}, как ожидают новые обработчики.
+// Тело восстанавливается, чтобы обработчик мог прочитать его целиком
+func idFromBody(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			respondWithError(w, bodyError(err))
+			return
+		}
+		var req struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			respondWithError(w, ErrInvalidJSON)
+			return
+		}
+
+		r.SetPathValue("id", strconv.Itoa(req.ID))
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		handler(w, r)
+	}
+}
+
+// idFromQuery переносит параметр запроса ?id= в параметр пути {id}
+func idFromQuery(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.SetPathValue("id", r.URL.Query().Get("id"))
+		handler(w, r)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testAPI маршрутизатор API поверх хранилища ds с выданным токеном администратора
+type testAPI struct {
+	handler    http.Handler
+	service    *Service
+	auth       *Auth
+	adminToken string
+}
+
+func newTestAPI(t *testing.T, ds DataSource) *testAPI {
+	t.Helper()
+	auth, accounts := newTestAuth(t, ds)
+	controller := NewController(accounts.service, accounts, RolePolicy{}, testLogger)
+	metrics := NewMetrics(ds)
+	router := newRouter(controller, NewHealth(ds, DefaultConfig().Health.Timeout.Duration, testLogger), metrics, auth)
+	api := &testAPI{
+		handler: limitBody(int64(DefaultConfig().HTTP.MaxBodyBytes), router),
+		service: accounts.service,
+		auth:    auth,
+	}
+	api.adminToken = api.token(t, Principal{Subject: testAdmin, Role: RoleAdmin})
+	return api
+}
+
+// token выдает access-токен для principal
+func (api *testAPI) token(t *testing.T, principal Principal) string {
+	t.Helper()
+	pair, err := api.auth.Issue(principal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pair.AccessToken
+}
+
+// do выполняет запрос с токеном token (пустой — без авторизации) и заголовками headers
+// в виде пар имя, значение
+func (api *testAPI) do(method, target, token, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	api.handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestLegacyRoutes(t *testing.T) {
+	api := newTestAPI(t, NewMemoryDataSource())
+	ctx := context.Background()
+	teacher, err := api.service.CreateTeacher(ctx, Teacher{Name: "Иван Петрович", Email: "ivan@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	student, err := api.service.CreateStudent(ctx, Student{Name: "Анна", Email: "anna@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	studentID := strconv.Itoa(student.ID)
+
+	// GET на устаревшем пути попадает в GET /students/{id} и ничего не удаляет
+	if w := api.do(http.MethodGet, "/students/delete?id="+studentID, api.adminToken, ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("GET /students/delete: status = %d, want 400: %s", w.Code, w.Body)
+	}
+	if _, err := api.service.GetStudentByID(ctx, student.ID); err != nil {
+		t.Fatalf("GET /students/delete changed data: %v", err)
+	}
+
+	update := `{"id":` + strconv.Itoa(teacher.ID) + `,"name":"Иван","email":"ivan@example.com"}`
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		ifMatch    string
+		wantStatus int
+		deprecated bool
+	}{
+		{"post create", http.MethodPost, "/teachers/create", `{"name":"Мария","email":"maria@example.com"}`, "", http.StatusCreated, true},
+		{"put update without If-Match", http.MethodPut, "/teachers/update", update, "", http.StatusPreconditionRequired, true},
+		{"put update with body id", http.MethodPut, "/teachers/update", update, "*", http.StatusOK, true},
+		{"delete with query id", http.MethodDelete, "/students/delete?id=" + studentID, "", "*", http.StatusOK, true},
+		{"id route still matches", http.MethodGet, "/teachers/" + strconv.Itoa(teacher.ID), "", "", http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{"If-Match", tt.ifMatch}
+			}
+			w := api.do(tt.method, tt.target, api.adminToken, tt.body, headers...)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if got := w.Header().Get("Deprecation") == "true"; got != tt.deprecated {
+				t.Fatalf("Deprecation header = %q", w.Header().Get("Deprecation"))
+			}
+		})
+	}
+
+	if _, err := api.service.GetStudentByID(ctx, student.ID); err == nil {
+		t.Fatal("DELETE /students/delete did not delete the student")
+	}
+}
+
+// decodeBody разбирает JSON-ответ в v
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+}
+
+func TestGetByID(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		api := newTestAPI(t, ds)
+		ctx := context.Background()
+		teacher := mustCreateTeacher(t, api.service, "Иван Петрович", "ivan@example.com")
+		student := mustCreateStudent(t, api.service, "Анна", "anna@example.com")
+		course, err := api.service.CreateCourse(ctx, Course{Title: "Go", TeacherID: teacher.ID, Price: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			target     string
+			wantStatus int
+			wantCode   string
+		}{
+			{"/teachers/" + strconv.Itoa(teacher.ID), http.StatusOK, ""},
+			{"/students/" + strconv.Itoa(student.ID), http.StatusOK, ""},
+			{"/courses/" + strconv.Itoa(course.ID), http.StatusOK, ""},
+			{"/teachers/999", http.StatusNotFound, "teacher_not_found"},
+			{"/students/999", http.StatusNotFound, "student_not_found"},
+			{"/courses/999", http.StatusNotFound, "course_not_found"},
+			{"/teachers/abc", http.StatusBadRequest, "invalid_id"},
+		}
+		for _, tt := range tests {
+			w := api.do(http.MethodGet, tt.target, api.adminToken, "")
+			if w.Code != tt.wantStatus {
+				t.Errorf("GET %s: status = %d, want %d: %s", tt.target, w.Code, tt.wantStatus, w.Body)
+				continue
+			}
+			if tt.wantCode != "" && errorCode(t, w) != tt.wantCode {
+				t.Errorf("GET %s: body = %s", tt.target, w.Body)
+			}
+		}
+
+		w := api.do(http.MethodGet, "/courses/"+strconv.Itoa(course.ID), api.adminToken, "")
+		var got Course
+		decodeBody(t, w, &got)
+		if got.ID != course.ID || got.Title != "Go" || got.TeacherID != teacher.ID || got.Price != 10 {
+			t.Fatalf("course = %+v", got)
+		}
+		if w.Header().Get("ETag") == "" {
+			t.Fatal("GET by ID returned no ETag")
+		}
+	})
+}
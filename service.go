@@ -1,152 +1,552 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"fmt"
-
-	_ "github.com/lib/pq"
+	"log/slog"
+	"net/url"
 )
 
-type DataSource struct {
-	db *sql.DB
+// Store операции с преподавателями, студентами, курсами и учетными записями.
+// Их выполняет как DataSource, так и транзакция, открытая DataSource.Transact.
+// Методы GetAll* получают ListParams, уже проверенные Service, и должны упорядочивать
+// записи одинаково: по колонке сортировки, затем по id.
+// Update* и Delete* выполняются, только если версия записи равна переданной (0 — любая),
+// иначе возвращают ErrVersionMismatch; каждое изменение увеличивает версию на 1.
+// Запрос к базе прерывается, когда ctx отменен или истек; транзакция при этом откатывается
+type Store interface {
+	GetAllTeachers(ctx context.Context, params ListParams) (Page[Teacher], error)
+	GetTeacherByID(ctx context.Context, id int) (Teacher, error)
+	CreateTeacher(ctx context.Context, teacher Teacher) (Teacher, error)
+	UpdateTeacher(ctx context.Context, teacher Teacher) (Teacher, error)
+	// ModifyTeacher атомарно заменяет преподавателя результатом modify от текущей записи;
+	// ошибка modify отменяет изменение
+	ModifyTeacher(ctx context.Context, id int, modify func(Teacher) (Teacher, error)) (Teacher, error)
+	DeleteTeacher(ctx context.Context, id, version int) error
+
+	GetAllStudents(ctx context.Context, params ListParams) (Page[Student], error)
+	GetStudentByID(ctx context.Context, id int) (Student, error)
+	CreateStudent(ctx context.Context, student Student) (Student, error)
+	UpdateStudent(ctx context.Context, student Student) (Student, error)
+	ModifyStudent(ctx context.Context, id int, modify func(Student) (Student, error)) (Student, error)
+	DeleteStudent(ctx context.Context, id, version int) error
+
+	GetAllCourses(ctx context.Context, params ListParams) (Page[Course], error)
+	GetCourseByID(ctx context.Context, id int) (Course, error)
+	CreateCourse(ctx context.Context, course Course) (Course, error)
+	UpdateCourse(ctx context.Context, course Course) (Course, error)
+	ModifyCourse(ctx context.Context, id int, modify func(Course) (Course, error)) (Course, error)
+	DeleteCourse(ctx context.Context, id, version int) error
+	// ReassignCourses передает все курсы преподавателя fromTeacherID преподавателю toTeacherID
+	// (0 — курсы остаются без преподавателя) и возвращает их число;
+	// несуществующий toTeacherID — ErrUnknownTeacher
+	ReassignCourses(ctx context.Context, fromTeacherID, toTeacherID int) (int, error)
+
+	EnrollStudent(ctx context.Context, studentID, courseID int) (Enrollment, error)
+	UnenrollStudent(ctx context.Context, studentID, courseID int) error
+	GetCourseStudents(ctx context.Context, courseID int) ([]Student, error)
+	GetStudentCourses(ctx context.Context, studentID int) ([]Course, error)
+
+	// Учетные записи пользователей
+	UserStore
+}
+
+// DataSource хранилище преподавателей, студентов и курсов.
+// Реализации: PostgresDataSource, SQLiteDataSource и MemoryDataSource
+type DataSource interface {
+	Store
+
+	// Transact выполняет fn атомарно: изменения, сделанные через tx, сохраняются, только если
+	// fn вернула nil, иначе откатываются все. fn может быть выполнена повторно, если транзакцию
+	// прервал конфликт с параллельной; вложенный Transact на tx выполняется в той же транзакции
+	Transact(ctx context.Context, fn func(tx Store) error) error
+
+	// Ping проверяет доступность хранилища для проверки готовности
+	Ping(ctx context.Context) error
+	Close() error
 }
 
 type Service struct {
-	dataSource *DataSource
+	dataSource DataSource
+	timeouts   QueryTimeouts
+	metrics    *Metrics
+	logger     *slog.Logger
 }
 
-// NewDataSource создает новый экземпляр DataSource с подключением к PostgreSQL
-func NewDataSource(dbURL string) (*DataSource, error) {
-	db, err := sql.Open("postgres", dbURL)
+// NewDataSource создает хранилище по схеме строки подключения:
+// postgres://... — PostgreSQL, sqlite://<файл> — SQLite, memory:// — хранилище в памяти
+func NewDataSource(cfg DBConfig) (DataSource, error) {
+	u, err := url.Parse(cfg.URL())
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid dsn: %w", err)
 	}
-	err = db.Ping()
-	if err != nil {
-		return nil, err
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		return NewPostgresDataSource(cfg)
+	case "sqlite":
+		return NewSQLiteDataSource(cfg)
+	case "memory":
+		return NewMemoryDataSource(), nil
+	default:
+		return nil, fmt.Errorf("unsupported dsn scheme %q", u.Scheme)
 	}
-
-	return &DataSource{db: db}, nil
 }
 
-// NewService создает новый экземпляр Service
-func NewService(dataSource *DataSource) *Service {
+// NewService создает новый экземпляр Service. Каждая операция с хранилищем ограничена
+// таймаутом из timeouts; прерванные операции учитываются в metrics
+func NewService(dataSource DataSource, timeouts QueryTimeouts, metrics *Metrics, logger *slog.Logger) *Service {
 	return &Service{
 		dataSource: dataSource,
+		timeouts:   timeouts,
+		metrics:    metrics,
+		logger:     logger,
 	}
 }
 
-func (s *Service) GetAllTeachers() ([]Teacher, error) {
-	rows, err := s.dataSource.db.Query("SELECT id, name FROM teachers")
+// GetAllTeachers возвращает страницу преподавателей
+func (s *Service) GetAllTeachers(ctx context.Context, params ListParams) (Page[Teacher], error) {
+	params, err := params.normalize(teacherSortColumns)
 	if err != nil {
-		fmt.Println(err)
-		return nil, err
+		return Page[Teacher]{}, err
 	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.List.Duration)
+	defer cancel()
+	teachers, err := s.dataSource.GetAllTeachers(ctx, params)
+	return teachers, s.storageError(ctx, "list_teachers", err)
+}
 
-	defer rows.Close()
+// GetAllStudents возвращает страницу студентов
+func (s *Service) GetAllStudents(ctx context.Context, params ListParams) (Page[Student], error) {
+	params, err := params.normalize(studentSortColumns)
+	if err != nil {
+		return Page[Student]{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.List.Duration)
+	defer cancel()
+	students, err := s.dataSource.GetAllStudents(ctx, params)
+	return students, s.storageError(ctx, "list_students", err)
+}
 
-	var teachers []Teacher
-	for rows.Next() {
-		var teacher Teacher
-		if err := rows.Scan(&teacher.ID, &teacher.Name); err != nil {
-			return nil, err
-		}
-		teachers = append(teachers, teacher)
+// GetAllCourses возвращает страницу курсов; фильтр Name применяется к названию
+func (s *Service) GetAllCourses(ctx context.Context, params ListParams) (Page[Course], error) {
+	if params.Email != "" {
+		return Page[Course]{}, invalidParam("email", "courses cannot be filtered by email")
 	}
+	params, err := params.normalize(courseSortColumns)
+	if err != nil {
+		return Page[Course]{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.List.Duration)
+	defer cancel()
+	courses, err := s.dataSource.GetAllCourses(ctx, params)
+	return courses, s.storageError(ctx, "list_courses", err)
+}
+
+// GetTeacherByID возвращает преподавателя по ID
+func (s *Service) GetTeacherByID(ctx context.Context, id int) (Teacher, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Read.Duration)
+	defer cancel()
+	teacher, err := s.dataSource.GetTeacherByID(ctx, id)
+	return teacher, s.storageError(ctx, "get_teacher", err)
+}
+
+// GetStudentByID возвращает студента по ID
+func (s *Service) GetStudentByID(ctx context.Context, id int) (Student, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Read.Duration)
+	defer cancel()
+	student, err := s.dataSource.GetStudentByID(ctx, id)
+	return student, s.storageError(ctx, "get_student", err)
+}
 
-	return teachers, nil
+// GetCourseByID возвращает курс по ID
+func (s *Service) GetCourseByID(ctx context.Context, id int) (Course, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Read.Duration)
+	defer cancel()
+	course, err := s.dataSource.GetCourseByID(ctx, id)
+	return course, s.storageError(ctx, "get_course", err)
 }
 
-func (s *Service) GetAllStudents() ([]Student, error) {
-	rows, err := s.dataSource.db.Query("SELECT id, name FROM students")
+// CreateTeacher создает преподавателя и возвращает его с присвоенным ID
+func (s *Service) CreateTeacher(ctx context.Context, teacher Teacher) (Teacher, error) {
+	teacher = teacher.normalize()
+	if err := validatePerson(teacher.Name, teacher.Email); err != nil {
+		return Teacher{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	teacher, err := s.dataSource.CreateTeacher(ctx, teacher)
 	if err != nil {
-		return nil, err
+		return Teacher{}, s.storageError(ctx, "create_teacher", err)
 	}
-	defer rows.Close()
+	s.logger.InfoContext(ctx, "teacher created", "id", teacher.ID, "email", teacher.Email)
+	return teacher, nil
+}
 
-	var students []Student
-	for rows.Next() {
-		var student Student
-		if err := rows.Scan(&student.ID, &student.Name); err != nil {
-			return nil, err
-		}
-		students = append(students, student)
+// CreateStudent создает студента и возвращает его с присвоенным ID
+func (s *Service) CreateStudent(ctx context.Context, student Student) (Student, error) {
+	student = student.normalize()
+	if err := validatePerson(student.Name, student.Email); err != nil {
+		return Student{}, err
 	}
-
-	return students, nil
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	student, err := s.dataSource.CreateStudent(ctx, student)
+	if err != nil {
+		return Student{}, s.storageError(ctx, "create_student", err)
+	}
+	s.logger.InfoContext(ctx, "student created", "id", student.ID, "email", student.Email)
+	return student, nil
 }
 
-func (s *Service) CreateTeacher(teacher Teacher) error {
-	_, err := s.dataSource.db.Exec("INSERT INTO teachers (name) VALUES ($1)", teacher.Name)
-	return err
+// CreateCourse создает курс и возвращает его с присвоенным ID
+func (s *Service) CreateCourse(ctx context.Context, course Course) (Course, error) {
+	course = course.normalize()
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	if err := s.validateCourse(ctx, course); err != nil {
+		return Course{}, err
+	}
+	course, err := s.dataSource.CreateCourse(ctx, course)
+	if err != nil {
+		return Course{}, s.storageError(ctx, "create_course", err)
+	}
+	s.logger.InfoContext(ctx, "course created", "id", course.ID, "title", course.Title, "teacher_id", course.TeacherID)
+	return course, nil
 }
 
-func (s *Service) CreateStudent(student Student) error {
-	_, err := s.dataSource.db.Exec("INSERT INTO students (name) VALUES ($1)", student.Name)
-
+// UpdateTeacher заменяет запись целиком; teacher.Version — ожидаемая версия (0 — любая).
+// Вместе с email меняется имя учетной записи преподавателя
+func (s *Service) UpdateTeacher(ctx context.Context, teacher Teacher) (Teacher, error) {
+	teacher = teacher.normalize()
+	if err := validatePerson(teacher.Name, teacher.Email); err != nil {
+		return Teacher{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	var updated Teacher
+	err := s.dataSource.Transact(ctx, func(tx Store) error {
+		var err error
+		if updated, err = tx.UpdateTeacher(ctx, teacher); err != nil {
+			return err
+		}
+		// Имя учетной записи преподавателя — его email
+		return tx.RenameTeacherUser(ctx, updated.ID, updated.Email)
+	})
 	if err != nil {
-		fmt.Println(" error creating student", err)
+		return Teacher{}, s.storageError(ctx, "update_teacher", err)
 	}
-	return err
+	s.logger.InfoContext(ctx, "teacher updated", "id", updated.ID, "version", updated.Version)
+	return updated, nil
 }
 
-func (s *Service) UpdateTeacher(teacher Teacher) error {
-	result, err := s.dataSource.db.Exec("UPDATE teachers SET name = $1 WHERE id = $2", teacher.Name, teacher.ID)
+// UpdateStudent заменяет запись целиком; student.Version — ожидаемая версия (0 — любая).
+// Вместе с email меняется имя учетной записи студента
+func (s *Service) UpdateStudent(ctx context.Context, student Student) (Student, error) {
+	student = student.normalize()
+	if err := validatePerson(student.Name, student.Email); err != nil {
+		return Student{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	var updated Student
+	err := s.dataSource.Transact(ctx, func(tx Store) error {
+		var err error
+		if updated, err = tx.UpdateStudent(ctx, student); err != nil {
+			return err
+		}
+		return tx.RenameStudentUser(ctx, updated.ID, updated.Email)
+	})
 	if err != nil {
-		return err
+		return Student{}, s.storageError(ctx, "update_student", err)
 	}
-	rowsAffected, err := result.RowsAffected()
+	s.logger.InfoContext(ctx, "student updated", "id", updated.ID, "version", updated.Version)
+	return updated, nil
+}
+
+// UpdateCourse заменяет курс целиком; course.Version — ожидаемая версия (0 — любая).
+// Как и в PatchCourse, allow получает текущую и новую запись в той же атомарной операции,
+// поэтому права проверяются по владельцу, который действительно будет перезаписан
+func (s *Service) UpdateCourse(ctx context.Context, course Course, allow func(current, updated Course) error) (Course, error) {
+	course = course.normalize()
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	course, err := s.dataSource.ModifyCourse(ctx, course.ID, func(current Course) (Course, error) {
+		if err := checkVersion(course.Version, current.Version); err != nil {
+			return Course{}, err
+		}
+		if err := allow(current, course); err != nil {
+			return Course{}, err
+		}
+		return course, checkCourse(course).err()
+	})
 	if err != nil {
-		return err
+		return Course{}, s.storageError(ctx, "update_course", err)
 	}
-	if rowsAffected == 0 {
-		return errors.New("teacher not found")
+	s.logger.InfoContext(ctx, "course updated", "id", course.ID, "version", course.Version)
+	return course, nil
+}
+
+// PatchTeacher применяет к преподавателю MergePatch и проверяет результат; version — ожидаемая
+// версия (0 — любая). Чтение, слияние и запись выполняются атомарно, поэтому параллельные
+// изменения не теряются
+func (s *Service) PatchTeacher(ctx context.Context, id, version int, patch MergePatch) (Teacher, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	var teacher Teacher
+	err := s.dataSource.Transact(ctx, func(tx Store) error {
+		var err error
+		teacher, err = tx.ModifyTeacher(ctx, id, func(current Teacher) (Teacher, error) {
+			if err := checkVersion(version, current.Version); err != nil {
+				return Teacher{}, err
+			}
+			var teacher Teacher
+			if err := patch.apply(current, &teacher); err != nil {
+				return Teacher{}, err
+			}
+			teacher = teacher.normalize()
+			return teacher, validatePerson(teacher.Name, teacher.Email)
+		})
+		if err != nil {
+			return err
+		}
+		return tx.RenameTeacherUser(ctx, teacher.ID, teacher.Email)
+	})
+	if err != nil {
+		return Teacher{}, s.storageError(ctx, "patch_teacher", err)
 	}
-	return nil
+	s.logger.InfoContext(ctx, "teacher patched", "id", id, "version", teacher.Version)
+	return teacher, nil
 }
 
-func (s *Service) UpdateStudent(student Student) error {
-	result, err := s.dataSource.db.Exec("UPDATE students SET name = $1 WHERE id = $2", student.Name, student.ID)
+// PatchStudent применяет к студенту MergePatch и проверяет результат
+func (s *Service) PatchStudent(ctx context.Context, id, version int, patch MergePatch) (Student, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	var student Student
+	err := s.dataSource.Transact(ctx, func(tx Store) error {
+		var err error
+		student, err = tx.ModifyStudent(ctx, id, func(current Student) (Student, error) {
+			if err := checkVersion(version, current.Version); err != nil {
+				return Student{}, err
+			}
+			var student Student
+			if err := patch.apply(current, &student); err != nil {
+				return Student{}, err
+			}
+			student = student.normalize()
+			return student, validatePerson(student.Name, student.Email)
+		})
+		if err != nil {
+			return err
+		}
+		return tx.RenameStudentUser(ctx, student.ID, student.Email)
+	})
 	if err != nil {
-		return err
+		return Student{}, s.storageError(ctx, "patch_student", err)
 	}
-	rowsAffected, err := result.RowsAffected()
+	s.logger.InfoContext(ctx, "student patched", "id", id, "version", student.Version)
+	return student, nil
+}
+
+// PatchCourse применяет к курсу MergePatch и проверяет результат. allow получает текущую
+// и измененную запись в той же атомарной операции и может запретить изменение,
+// например передачу курса другому преподавателю. Существование преподавателя
+// проверяет хранилище (ErrUnknownTeacher)
+func (s *Service) PatchCourse(ctx context.Context, id, version int, patch MergePatch, allow func(current, patched Course) error) (Course, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	course, err := s.dataSource.ModifyCourse(ctx, id, func(current Course) (Course, error) {
+		if err := checkVersion(version, current.Version); err != nil {
+			return Course{}, err
+		}
+		var course Course
+		if err := patch.apply(current, &course); err != nil {
+			return Course{}, err
+		}
+		course = course.normalize()
+		if err := allow(current, course); err != nil {
+			return Course{}, err
+		}
+		return course, checkCourse(course).err()
+	})
 	if err != nil {
-		return err
+		return Course{}, s.storageError(ctx, "patch_course", err)
+	}
+	s.logger.InfoContext(ctx, "course patched", "id", id, "version", course.Version)
+	return course, nil
+}
+
+func (s *Service) DeleteTeacher(ctx context.Context, id, version int) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	if err := s.dataSource.DeleteTeacher(ctx, id, version); err != nil {
+		return s.storageError(ctx, "delete_teacher", err)
 	}
-	if rowsAffected == 0 {
-		return errors.New("student not found")
+	s.logger.InfoContext(ctx, "teacher deleted", "id", id)
+	return nil
+}
+
+func (s *Service) DeleteStudent(ctx context.Context, id, version int) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	if err := s.dataSource.DeleteStudent(ctx, id, version); err != nil {
+		return s.storageError(ctx, "delete_student", err)
 	}
+	s.logger.InfoContext(ctx, "student deleted", "id", id)
 	return nil
 }
 
-func (s *Service) DeleteTeacher(id int) error {
-	result, err := s.dataSource.db.Exec("DELETE FROM teachers WHERE id = $1", id)
+func (s *Service) DeleteCourse(ctx context.Context, id, version int) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	if err := s.dataSource.DeleteCourse(ctx, id, version); err != nil {
+		return s.storageError(ctx, "delete_course", err)
+	}
+	s.logger.InfoContext(ctx, "course deleted", "id", id)
+	return nil
+}
+
+// ReassignAndDeleteTeacher передает курсы преподавателя id преподавателю successorID и удаляет
+// преподавателя в одной транзакции: если удалить его нельзя (например, не совпала версия),
+// курсы остаются за ним. Возвращает число переданных курсов
+func (s *Service) ReassignAndDeleteTeacher(ctx context.Context, id, version, successorID int) (int, error) {
+	if successorID == id {
+		return 0, invalidParam("reassign_to", "courses cannot be reassigned to the deleted teacher")
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	var reassigned int
+	err := s.dataSource.Transact(ctx, func(tx Store) error {
+		var err error
+		reassigned, err = tx.ReassignCourses(ctx, id, successorID)
+		if errors.Is(err, ErrUnknownTeacher) {
+			return &Error{Kind: KindUnprocessable, Code: ErrUnknownTeacher.Code, Field: "reassign_to", Message: "reassign_to refers to a missing teacher"}
+		}
+		if err != nil {
+			return err
+		}
+		return tx.DeleteTeacher(ctx, id, version)
+	})
+	if err != nil {
+		return 0, s.storageError(ctx, "reassign_delete_teacher", err)
+	}
+	s.logger.InfoContext(ctx, "teacher deleted", "id", id, "courses_reassigned_to", successorID, "courses", reassigned)
+	return reassigned, nil
+}
+
+// EnrollStudent записывает студента на курс
+func (s *Service) EnrollStudent(ctx context.Context, studentID, courseID int) (Enrollment, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	enrollment, err := s.dataSource.EnrollStudent(ctx, studentID, courseID)
 	if err != nil {
-		return err
+		return Enrollment{}, s.storageError(ctx, "enroll_student", err)
+	}
+	s.logger.InfoContext(ctx, "student enrolled", "student_id", studentID, "course_id", courseID)
+	return enrollment, nil
+}
+
+// CreateCourseWithStudents создает курс и записывает на него студентов studentIDs в одной
+// транзакции: если кого-то из них записать нельзя, курс не создается
+func (s *Service) CreateCourseWithStudents(ctx context.Context, course Course, studentIDs []int) (Course, []Enrollment, error) {
+	course = course.normalize()
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	if err := s.validateCourse(ctx, course); err != nil {
+		return Course{}, nil, err
 	}
-	rowsAffected, err := result.RowsAffected()
+	var created Course
+	var enrollments []Enrollment
+	err := s.dataSource.Transact(ctx, func(tx Store) error {
+		var err error
+		if created, err = tx.CreateCourse(ctx, course); err != nil {
+			return err
+		}
+		enrollments = make([]Enrollment, 0, len(studentIDs))
+		for _, studentID := range studentIDs {
+			enrollment, err := tx.EnrollStudent(ctx, studentID, created.ID)
+			if errors.Is(err, ErrStudentNotFound) {
+				return ErrUnknownStudent
+			}
+			if err != nil {
+				return err
+			}
+			enrollments = append(enrollments, enrollment)
+		}
+		return nil
+	})
 	if err != nil {
-		return err
+		return Course{}, nil, s.storageError(ctx, "create_course_with_students", err)
 	}
-	if rowsAffected == 0 {
-		return errors.New("teacher not found")
+	s.logger.InfoContext(ctx, "course created", "id", created.ID, "title", created.Title,
+		"teacher_id", created.TeacherID, "students", len(enrollments))
+	return created, enrollments, nil
+}
+
+// UnenrollStudent отписывает студента от курса
+func (s *Service) UnenrollStudent(ctx context.Context, studentID, courseID int) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.Write.Duration)
+	defer cancel()
+	if err := s.dataSource.UnenrollStudent(ctx, studentID, courseID); err != nil {
+		return s.storageError(ctx, "unenroll_student", err)
 	}
+	s.logger.InfoContext(ctx, "student unenrolled", "student_id", studentID, "course_id", courseID)
 	return nil
 }
 
-func (s *Service) DeleteStudent(id int) error {
-	result, err := s.dataSource.db.Exec("DELETE FROM students WHERE id = $1", id)
-	if err != nil {
-		return err
+// GetCourseStudents возвращает студентов, записанных на курс
+func (s *Service) GetCourseStudents(ctx context.Context, courseID int) ([]Student, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.List.Duration)
+	defer cancel()
+	students, err := s.dataSource.GetCourseStudents(ctx, courseID)
+	return students, s.storageError(ctx, "list_course_students", err)
+}
+
+// GetStudentCourses возвращает курсы, на которые записан студент
+func (s *Service) GetStudentCourses(ctx context.Context, studentID int) ([]Course, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeouts.List.Duration)
+	defer cancel()
+	courses, err := s.dataSource.GetStudentCourses(ctx, studentID)
+	return courses, s.storageError(ctx, "list_student_courses", err)
+}
+
+// storageError приводит ошибку хранилища к *Error. Если ctx к этому моменту отменен или истек,
+// причиной считается он, а не ошибка драйвера: PostgreSQL и SQLite сообщают о прерванном
+// запросе каждый по-своему. Прерванные операции пишутся в лог и в метрику
+// storage_operations_interrupted_total с меткой operation
+func (s *Service) storageError(ctx context.Context, operation string, err error) error {
+	var domainErr *Error
+	if err == nil || ctx.Err() == nil || errors.As(err, &domainErr) {
+		return domainError(err)
 	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
+	reason := interruptReason(ctx.Err())
+	s.metrics.ObserveInterrupted(operation, reason)
+	s.logger.WarnContext(ctx, "storage operation interrupted", "operation", operation, "reason", reason, "error", err)
+	if !errors.Is(err, ctx.Err()) {
+		err = fmt.Errorf("%w: %w", ctx.Err(), err)
 	}
-	if rowsAffected == 0 {
-		return errors.New("student not found")
+	return domainError(err)
+}
+
+// checkVersion сравнивает ожидаемую версию записи с текущей; expected 0 — любая версия
+func checkVersion(expected, actual int) error {
+	if expected != 0 && expected != actual {
+		return ErrVersionMismatch
 	}
 	return nil
 }
+
+// validateCourse проверяет поля курса и существование преподавателя teacher_id
+func (s *Service) validateCourse(ctx context.Context, course Course) error {
+	v := checkCourse(course)
+	if course.TeacherID > 0 {
+		_, err := s.dataSource.GetTeacherByID(ctx, course.TeacherID)
+		if errors.Is(err, ErrTeacherNotFound) {
+			v.check(false, "teacher_id", ErrUnknownTeacher.Code, ErrUnknownTeacher.Message)
+		} else if err != nil {
+			return s.storageError(ctx, "get_teacher", err)
+		}
+	}
+	return v.err()
+}
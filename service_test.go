@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var testLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// testBackends хранилища, на которых выполняются тесты: поведение MemoryDataSource
+// и SQL-реализации должно совпадать
+var testBackends = []struct {
+	name string
+	open func(t *testing.T) DataSource
+}{
+	{"memory", func(t *testing.T) DataSource {
+		return NewMemoryDataSource()
+	}},
+	{"sqlite", func(t *testing.T) DataSource {
+		ds, err := NewSQLiteDataSource(DBConfig{DSN: "sqlite://" + filepath.Join(t.TempDir(), "test.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ds.Close() })
+		return ds
+	}},
+	{"postgres", openTestPostgres},
+}
+
+// openTestPostgres подключается к PostgreSQL из TEST_POSTGRES_DSN (URL postgres://...)
+// и создает для теста отдельную схему; без переменной тест пропускается
+func openTestPostgres(t *testing.T) DataSource {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	admin, err := sql.Open(postgresDialect.driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if db, err := sql.Open(postgresDialect.driver, dsn); err == nil {
+			db.Exec("DROP SCHEMA " + schema + " CASCADE")
+			db.Close()
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	cfg := DefaultConfig().DB
+	cfg.DSN = u.String()
+	ds, err := NewPostgresDataSource(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ds.Close() })
+	return ds
+}
+
+// forEachBackend выполняет fn как подтест для каждого хранилища из testBackends
+func forEachBackend(t *testing.T, fn func(t *testing.T, ds DataSource)) {
+	t.Helper()
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) {
+			fn(t, backend.open(t))
+		})
+	}
+}
+
+func newTestService(ds DataSource) *Service {
+	return NewService(ds, DefaultConfig().DB.Timeouts, NewMetrics(ds), testLogger)
+}
+
+func mustCreateStudent(t *testing.T, s *Service, name, email string) Student {
+	t.Helper()
+	student, err := s.CreateStudent(context.Background(), Student{Name: name, Email: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return student
+}
+
+func mustCreateTeacher(t *testing.T, s *Service, name, email string) Teacher {
+	t.Helper()
+	teacher, err := s.CreateTeacher(context.Background(), Teacher{Name: name, Email: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return teacher
+}
+
+func TestCreateCourseWithStudents(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		ctx := context.Background()
+		s := newTestService(ds)
+		teacher := mustCreateTeacher(t, s, "Иван Петрович", "ivan@example.com")
+		first := mustCreateStudent(t, s, "Анна", "anna@example.com")
+		second := mustCreateStudent(t, s, "Борис", "boris@example.com")
+
+		tests := []struct {
+			name       string
+			studentIDs []int
+			wantErr    error
+		}{
+			{"unknown student", []int{first.ID, second.ID + 100}, ErrUnknownStudent},
+			{"duplicate student", []int{first.ID, first.ID}, ErrAlreadyEnrolled},
+			{"cohort", []int{first.ID, second.ID}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				course, enrollments, err := s.CreateCourseWithStudents(ctx, Course{Title: "Go " + tt.name, TeacherID: teacher.ID, Price: 10}, tt.studentIDs)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr == nil {
+					if len(enrollments) != len(tt.studentIDs) {
+						t.Fatalf("enrollments = %d, want %d", len(enrollments), len(tt.studentIDs))
+					}
+					if _, err := s.GetCourseByID(ctx, course.ID); err != nil {
+						t.Fatalf("created course is missing: %v", err)
+					}
+					return
+				}
+
+				// Транзакция откатывается целиком: ни курса, ни записей первого студента
+				courses, err := s.GetAllCourses(ctx, ListParams{Name: "Go " + tt.name})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if courses.Total != 0 {
+					t.Fatalf("course was kept after a failed transaction: %+v", courses.Items)
+				}
+				enrolled, err := s.GetStudentCourses(ctx, first.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(enrolled) != 0 {
+					t.Fatalf("enrollment was kept after a failed transaction: %+v", enrolled)
+				}
+			})
+		}
+	})
+}
+
+func TestReassignAndDeleteTeacherRollsBack(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		ctx := context.Background()
+		s := newTestService(ds)
+		leaving := mustCreateTeacher(t, s, "Уходящий", "leaving@example.com")
+		successor := mustCreateTeacher(t, s, "Преемник", "successor@example.com")
+		course, err := s.CreateCourse(ctx, Course{Title: "Алгоритмы", TeacherID: leaving.ID, Price: 5})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name        string
+			version     int
+			successorID int
+			wantKind    ErrorKind
+		}{
+			{"stale version", leaving.Version + 1, successor.ID, KindPrecondition},
+			{"unknown successor", leaving.Version, successor.ID + 100, KindUnprocessable},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := s.ReassignAndDeleteTeacher(ctx, leaving.ID, tt.version, tt.successorID)
+				var domainErr *Error
+				if !errors.As(err, &domainErr) || domainErr.Kind != tt.wantKind {
+					t.Fatalf("err = %v, want kind %v", err, tt.wantKind)
+				}
+				got, err := s.GetCourseByID(ctx, course.ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.TeacherID != leaving.ID || got.Version != course.Version {
+					t.Fatalf("course changed by a rolled back transaction: %+v", got)
+				}
+				if _, err := s.GetTeacherByID(ctx, leaving.ID); err != nil {
+					t.Fatalf("teacher deleted by a rolled back transaction: %v", err)
+				}
+			})
+		}
+
+		moved, err := s.ReassignAndDeleteTeacher(ctx, leaving.ID, leaving.Version, successor.ID)
+		if err != nil || moved != 1 {
+			t.Fatalf("reassign = %d, %v; want 1, nil", moved, err)
+		}
+		got, err := s.GetCourseByID(ctx, course.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.TeacherID != successor.ID {
+			t.Fatalf("course teacher = %d, want %d", got.TeacherID, successor.ID)
+		}
+	})
+}
+
+func TestEnrollStudent(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		ctx := context.Background()
+		s := newTestService(ds)
+		teacher := mustCreateTeacher(t, s, "Иван Петрович", "ivan@example.com")
+		course, err := s.CreateCourse(ctx, Course{Title: "Go", TeacherID: teacher.ID, Price: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		student := mustCreateStudent(t, s, "Анна", "anna@example.com")
+
+		tests := []struct {
+			name      string
+			studentID int
+			courseID  int
+			wantErr   error
+		}{
+			{"enrolled", student.ID, course.ID, nil},
+			{"again", student.ID, course.ID, ErrAlreadyEnrolled},
+			{"unknown student", 999, course.ID, ErrStudentNotFound},
+			{"unknown course", student.ID, 999, ErrCourseNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := s.EnrollStudent(ctx, tt.studentID, tt.courseID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			})
+		}
+
+		courses, err := s.GetStudentCourses(ctx, student.ID)
+		if err != nil || len(courses) != 1 || courses[0].ID != course.ID {
+			t.Fatalf("student courses = %+v, %v", courses, err)
+		}
+		if err := s.UnenrollStudent(ctx, student.ID, course.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.UnenrollStudent(ctx, student.ID, course.ID); !errors.Is(err, ErrEnrollmentNotFound) {
+			t.Fatalf("second unenroll: err = %v", err)
+		}
+	})
+}
+
+// Строку удаляют между проверкой и вставкой, поэтому гонку проверяем на разборе ошибки
+func TestMissingEnrollmentRef(t *testing.T) {
+	ds, err := NewSQLiteDataSource(DBConfig{DSN: "sqlite://" + filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	student := mustCreateStudent(t, newTestService(ds), "Анна", "anna@example.com")
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		err       error
+		studentID int
+		want      error
+	}{
+		{"postgres student", &pq.Error{Code: "23503", Constraint: "enrollments_student_id_fkey"}, student.ID, ErrStudentNotFound},
+		{"postgres course", &pq.Error{Code: "23503", Constraint: "enrollments_course_id_fkey"}, student.ID, ErrCourseNotFound},
+		{"sqlite student deleted", errors.New("FOREIGN KEY constraint failed"), 999, ErrStudentNotFound},
+		{"sqlite course deleted", errors.New("FOREIGN KEY constraint failed"), student.ID, ErrCourseNotFound},
+	}
+	for _, tt := range tests {
+		if got := ds.missingEnrollmentRef(ctx, tt.err, tt.studentID); !errors.Is(got, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Хранилища должны одинаково отвечать на одни и те же операции, чтобы обработчики
+// работали без изменений с любым из них
+func TestStoreCRUD(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		ctx := context.Background()
+
+		teacher, err := ds.CreateTeacher(ctx, Teacher{Name: "Иван Петрович", Email: "ivan@example.com"})
+		if err != nil || teacher.ID == 0 || teacher.Version == 0 {
+			t.Fatalf("create teacher = %+v, %v", teacher, err)
+		}
+		if got, err := ds.GetTeacherByID(ctx, teacher.ID); err != nil || got != teacher {
+			t.Fatalf("get teacher = %+v, %v; want %+v", got, err, teacher)
+		}
+		stale := teacher
+		teacher.Name = "Иван Петрович Сидоров"
+		if teacher, err = ds.UpdateTeacher(ctx, teacher); err != nil || teacher.Version != stale.Version+1 {
+			t.Fatalf("update teacher = %+v, %v", teacher, err)
+		}
+		if _, err := ds.UpdateTeacher(ctx, stale); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("update with a stale version: err = %v", err)
+		}
+
+		if _, err := ds.CreateCourse(ctx, Course{Title: "Go", TeacherID: 999}); !errors.Is(err, ErrUnknownTeacher) {
+			t.Fatalf("course of an unknown teacher: err = %v", err)
+		}
+		course, err := ds.CreateCourse(ctx, Course{Title: "Go", Description: "Основы", TeacherID: teacher.ID, Price: 10.5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ds.GetCourseByID(ctx, course.ID); err != nil || got != course {
+			t.Fatalf("get course = %+v, %v; want %+v", got, err, course)
+		}
+		if err := ds.DeleteTeacher(ctx, teacher.ID, 0); !errors.Is(err, ErrTeacherHasCourses) {
+			t.Fatalf("delete a teacher with courses: err = %v", err)
+		}
+		if n, err := ds.ReassignCourses(ctx, teacher.ID, 0); err != nil || n != 1 {
+			t.Fatalf("reassign courses = %d, %v", n, err)
+		}
+		if got, _ := ds.GetCourseByID(ctx, course.ID); got.TeacherID != 0 {
+			t.Fatalf("course teacher after reassign = %d", got.TeacherID)
+		}
+
+		student, err := ds.CreateStudent(ctx, Student{Name: "Анна", Email: "anna@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ds.DeleteStudent(ctx, student.ID, student.Version+1); !errors.Is(err, ErrVersionMismatch) {
+			t.Fatalf("delete with a stale version: err = %v", err)
+		}
+
+		for _, step := range []struct {
+			name     string
+			delete   func() error
+			get      func() error
+			notFound error
+		}{
+			{"teacher", func() error { return ds.DeleteTeacher(ctx, teacher.ID, teacher.Version) },
+				func() error { _, err := ds.GetTeacherByID(ctx, teacher.ID); return err }, ErrTeacherNotFound},
+			{"student", func() error { return ds.DeleteStudent(ctx, student.ID, student.Version) },
+				func() error { _, err := ds.GetStudentByID(ctx, student.ID); return err }, ErrStudentNotFound},
+			{"course", func() error { return ds.DeleteCourse(ctx, course.ID, 0) },
+				func() error { _, err := ds.GetCourseByID(ctx, course.ID); return err }, ErrCourseNotFound},
+		} {
+			if err := step.delete(); err != nil {
+				t.Fatalf("delete %s: %v", step.name, err)
+			}
+			if err := step.get(); !errors.Is(err, step.notFound) {
+				t.Fatalf("get deleted %s: err = %v", step.name, err)
+			}
+			if err := step.delete(); !errors.Is(err, step.notFound) {
+				t.Fatalf("delete %s again: err = %v", step.name, err)
+			}
+		}
+	})
+}
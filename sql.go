@@ -0,0 +1,809 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqlDataSource общая реализация DataSource на database/sql для PostgreSQL и SQLite.
+// Запросы у них одни и те же, различия синтаксиса описывает dialect
+type sqlDataSource struct {
+	db *sql.DB
+	// tx транзакция, открытая Transact; nil — каждый запрос выполняется отдельно
+	tx       *sql.Tx
+	migrator *Migrator
+	dialect  dialect
+}
+
+// dialect различия SQL между поддерживаемыми базами данных
+type dialect struct {
+	// driver имя драйвера database/sql
+	driver string
+	// fold SQL-функция, приводящая строку к нижнему регистру так же, как foldCase
+	fold string
+	// collate правило побайтного сравнения строк при сортировке
+	collate string
+	// lockRow дописывается к SELECT, чтобы заблокировать строку до конца транзакции
+	lockRow string
+	// timestamp тип колонок с моментом времени
+	timestamp string
+	// isolation уровень изоляции транзакций Transact
+	isolation sql.IsolationLevel
+	// migrations каталог миграций схемы
+	migrations string
+	// lockMigrations и unlockMigrations берут и отпускают блокировку, под которой
+	// применяются миграции; пустые, если база блокирует запись сама
+	lockMigrations   string
+	unlockMigrations string
+}
+
+// openDatabase открывает пул соединений с базой данных по схеме DSN
+func openDatabase(cfg DBConfig) (*sql.DB, dialect, error) {
+	u, err := url.Parse(cfg.URL())
+	if err != nil {
+		return nil, dialect{}, fmt.Errorf("invalid dsn: %w", err)
+	}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		db, err := openPostgres(cfg)
+		return db, postgresDialect, err
+	case "sqlite":
+		db, err := openSQLite(cfg)
+		return db, sqliteDialect, err
+	}
+	return nil, dialect{}, fmt.Errorf("dsn scheme %q does not name a SQL database", u.Scheme)
+}
+
+// newSQLDataSource проверяет соединение и применяет непримененные миграции схемы.
+// При ошибке пул соединений закрывается
+func newSQLDataSource(db *sql.DB, d dialect) (*sqlDataSource, error) {
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	migrator, err := NewMigrator(db, d)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrator.Up(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqlDataSource{db: db, migrator: migrator, dialect: d}, nil
+}
+
+// Ping проверяет соединение с базой данных
+func (ds *sqlDataSource) Ping(ctx context.Context) error {
+	return ds.db.PingContext(ctx)
+}
+
+// PendingMigrations возвращает непримененные миграции схемы
+func (ds *sqlDataSource) PendingMigrations(ctx context.Context) ([]Migration, error) {
+	return ds.migrator.Pending(ctx)
+}
+
+// Stats возвращает статистику пула соединений для /metrics
+func (ds *sqlDataSource) Stats() sql.DBStats {
+	return ds.db.Stats()
+}
+
+// Close закрывает пул соединений
+func (ds *sqlDataSource) Close() error {
+	return ds.db.Close()
+}
+
+func (ds *sqlDataSource) GetAllTeachers(ctx context.Context, params ListParams) (Page[Teacher], error) {
+	query, countQuery, args, countArgs := ds.dialect.listQuery("id, name, email", "teachers", "name", params)
+
+	var total int
+	if err := ds.conn().QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return Page[Teacher]{}, err
+	}
+
+	rows, err := ds.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return Page[Teacher]{}, err
+	}
+
+	defer rows.Close()
+
+	var teachers []Teacher
+	for rows.Next() {
+		var teacher Teacher
+		if err := rows.Scan(&teacher.ID, &teacher.Name, &teacher.Email); err != nil {
+			return Page[Teacher]{}, err
+		}
+		teachers = append(teachers, teacher)
+	}
+
+	return newPage(teachers, total, params), rows.Err()
+}
+
+func (ds *sqlDataSource) GetAllStudents(ctx context.Context, params ListParams) (Page[Student], error) {
+	query, countQuery, args, countArgs := ds.dialect.listQuery("id, name, email", "students", "name", params)
+
+	var total int
+	if err := ds.conn().QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return Page[Student]{}, err
+	}
+
+	rows, err := ds.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return Page[Student]{}, err
+	}
+	defer rows.Close()
+
+	var students []Student
+	for rows.Next() {
+		var student Student
+		if err := rows.Scan(&student.ID, &student.Name, &student.Email); err != nil {
+			return Page[Student]{}, err
+		}
+		students = append(students, student)
+	}
+
+	return newPage(students, total, params), rows.Err()
+}
+
+func (ds *sqlDataSource) GetAllCourses(ctx context.Context, params ListParams) (Page[Course], error) {
+	query, countQuery, args, countArgs := ds.dialect.listQuery(
+		"id, title, COALESCE(description, ''), teacher_id, price", "courses", "title", params)
+
+	var total int
+	if err := ds.conn().QueryRowContext(ctx, countQuery, countArgs...).Scan(&total); err != nil {
+		return Page[Course]{}, err
+	}
+
+	rows, err := ds.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return Page[Course]{}, err
+	}
+	defer rows.Close()
+
+	var courses []Course
+	for rows.Next() {
+		var course Course
+		var teacherID sql.NullInt64
+		if err := rows.Scan(&course.ID, &course.Title, &course.Description, &teacherID, &course.Price); err != nil {
+			return Page[Course]{}, err
+		}
+		course.TeacherID = int(teacherID.Int64)
+		courses = append(courses, course)
+	}
+
+	return newPage(courses, total, params), rows.Err()
+}
+
+func (ds *sqlDataSource) GetTeacherByID(ctx context.Context, id int) (Teacher, error) {
+	return getTeacher(ctx, ds.conn(), id, "")
+}
+
+func (ds *sqlDataSource) GetStudentByID(ctx context.Context, id int) (Student, error) {
+	return getStudent(ctx, ds.conn(), id, "")
+}
+
+func (ds *sqlDataSource) GetCourseByID(ctx context.Context, id int) (Course, error) {
+	return getCourse(ctx, ds.conn(), id, "")
+}
+
+// getTeacher читает преподавателя; lock дописывается к запросу, например FOR UPDATE
+func getTeacher(ctx context.Context, q execer, id int, lock string) (Teacher, error) {
+	var teacher Teacher
+	err := q.QueryRowContext(ctx, "SELECT id, name, email, version FROM teachers WHERE id = $1 "+lock, id).
+		Scan(&teacher.ID, &teacher.Name, &teacher.Email, &teacher.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Teacher{}, ErrTeacherNotFound
+	}
+	return teacher, err
+}
+
+func getStudent(ctx context.Context, q execer, id int, lock string) (Student, error) {
+	var student Student
+	err := q.QueryRowContext(ctx, "SELECT id, name, email, version FROM students WHERE id = $1 "+lock, id).
+		Scan(&student.ID, &student.Name, &student.Email, &student.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Student{}, ErrStudentNotFound
+	}
+	return student, err
+}
+
+func getCourse(ctx context.Context, q execer, id int, lock string) (Course, error) {
+	var course Course
+	var teacherID sql.NullInt64
+	err := q.QueryRowContext(ctx, "SELECT id, title, COALESCE(description, ''), teacher_id, price, version FROM courses WHERE id = $1 "+lock, id).
+		Scan(&course.ID, &course.Title, &course.Description, &teacherID, &course.Price, &course.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Course{}, ErrCourseNotFound
+	}
+	course.TeacherID = int(teacherID.Int64)
+	return course, err
+}
+
+func (ds *sqlDataSource) CreateTeacher(ctx context.Context, teacher Teacher) (Teacher, error) {
+	err := ds.conn().QueryRowContext(ctx, "INSERT INTO teachers (name, email) VALUES ($1, $2) RETURNING id, version", teacher.Name, teacher.Email).
+		Scan(&teacher.ID, &teacher.Version)
+	if isUniqueViolation(err) {
+		return Teacher{}, emailTaken(teacher.Email)
+	}
+	return teacher, err
+}
+
+func (ds *sqlDataSource) CreateStudent(ctx context.Context, student Student) (Student, error) {
+	err := ds.conn().QueryRowContext(ctx, "INSERT INTO students (name, email) VALUES ($1, $2) RETURNING id, version", student.Name, student.Email).
+		Scan(&student.ID, &student.Version)
+	if isUniqueViolation(err) {
+		return Student{}, emailTaken(student.Email)
+	}
+	return student, err
+}
+
+func (ds *sqlDataSource) CreateCourse(ctx context.Context, course Course) (Course, error) {
+	err := ds.conn().QueryRowContext(ctx, "INSERT INTO courses (title, description, teacher_id, price) VALUES ($1, $2, $3, $4) RETURNING id, version",
+		course.Title, course.Description, nullID(course.TeacherID), course.Price).Scan(&course.ID, &course.Version)
+	if isForeignKeyViolation(err) {
+		return Course{}, ErrUnknownTeacher
+	}
+	return course, err
+}
+
+func (ds *sqlDataSource) UpdateTeacher(ctx context.Context, teacher Teacher) (Teacher, error) {
+	return updateTeacher(ctx, ds.conn(), teacher)
+}
+
+func (ds *sqlDataSource) UpdateStudent(ctx context.Context, student Student) (Student, error) {
+	return updateStudent(ctx, ds.conn(), student)
+}
+
+func (ds *sqlDataSource) UpdateCourse(ctx context.Context, course Course) (Course, error) {
+	return updateCourse(ctx, ds.conn(), course)
+}
+
+// ModifyTeacher блокирует строку преподавателя до конца транзакции, поэтому параллельные
+// изменения применяются по очереди
+func (ds *sqlDataSource) ModifyTeacher(ctx context.Context, id int, modify func(Teacher) (Teacher, error)) (Teacher, error) {
+	var teacher Teacher
+	err := ds.inTx(ctx, sql.LevelDefault, func(tx *sql.Tx) error {
+		current, err := getTeacher(ctx, tx, id, ds.dialect.lockRow)
+		if err != nil {
+			return err
+		}
+		if teacher, err = modify(current); err != nil {
+			return err
+		}
+		teacher.ID, teacher.Version = id, current.Version
+		teacher, err = updateTeacher(ctx, tx, teacher)
+		return err
+	})
+	return teacher, err
+}
+
+func (ds *sqlDataSource) ModifyStudent(ctx context.Context, id int, modify func(Student) (Student, error)) (Student, error) {
+	var student Student
+	err := ds.inTx(ctx, sql.LevelDefault, func(tx *sql.Tx) error {
+		current, err := getStudent(ctx, tx, id, ds.dialect.lockRow)
+		if err != nil {
+			return err
+		}
+		if student, err = modify(current); err != nil {
+			return err
+		}
+		student.ID, student.Version = id, current.Version
+		student, err = updateStudent(ctx, tx, student)
+		return err
+	})
+	return student, err
+}
+
+func (ds *sqlDataSource) ModifyCourse(ctx context.Context, id int, modify func(Course) (Course, error)) (Course, error) {
+	var course Course
+	err := ds.inTx(ctx, sql.LevelDefault, func(tx *sql.Tx) error {
+		current, err := getCourse(ctx, tx, id, ds.dialect.lockRow)
+		if err != nil {
+			return err
+		}
+		if course, err = modify(current); err != nil {
+			return err
+		}
+		course.ID, course.Version = id, current.Version
+		course, err = updateCourse(ctx, tx, course)
+		return err
+	})
+	return course, err
+}
+
+// updateTeacher записывает преподавателя, если его версия совпадает с teacher.Version
+// (0 — любая), и возвращает его с новой версией
+func updateTeacher(ctx context.Context, q execer, teacher Teacher) (Teacher, error) {
+	err := q.QueryRowContext(ctx, `UPDATE teachers SET name = $1, email = $2, version = version + 1
+		WHERE id = $3 AND ($4 = 0 OR version = $4) RETURNING version`,
+		teacher.Name, teacher.Email, teacher.ID, teacher.Version).Scan(&teacher.Version)
+	switch {
+	case isUniqueViolation(err):
+		return Teacher{}, emailTaken(teacher.Email)
+	case errors.Is(err, sql.ErrNoRows):
+		return Teacher{}, versionConflict(ctx, q, "teachers", teacher.ID, ErrTeacherNotFound)
+	}
+	return teacher, err
+}
+
+func updateStudent(ctx context.Context, q execer, student Student) (Student, error) {
+	err := q.QueryRowContext(ctx, `UPDATE students SET name = $1, email = $2, version = version + 1
+		WHERE id = $3 AND ($4 = 0 OR version = $4) RETURNING version`,
+		student.Name, student.Email, student.ID, student.Version).Scan(&student.Version)
+	switch {
+	case isUniqueViolation(err):
+		return Student{}, emailTaken(student.Email)
+	case errors.Is(err, sql.ErrNoRows):
+		return Student{}, versionConflict(ctx, q, "students", student.ID, ErrStudentNotFound)
+	}
+	return student, err
+}
+
+func updateCourse(ctx context.Context, q execer, course Course) (Course, error) {
+	err := q.QueryRowContext(ctx, `UPDATE courses SET title = $1, description = $2, teacher_id = $3, price = $4, version = version + 1
+		WHERE id = $5 AND ($6 = 0 OR version = $6) RETURNING version`,
+		course.Title, course.Description, nullID(course.TeacherID), course.Price, course.ID, course.Version).Scan(&course.Version)
+	switch {
+	case isForeignKeyViolation(err):
+		return Course{}, ErrUnknownTeacher
+	case errors.Is(err, sql.ErrNoRows):
+		return Course{}, versionConflict(ctx, q, "courses", course.ID, ErrCourseNotFound)
+	}
+	return course, err
+}
+
+func (ds *sqlDataSource) DeleteTeacher(ctx context.Context, id, version int) error {
+	result, err := ds.conn().ExecContext(ctx, "DELETE FROM teachers WHERE id = $1 AND ($2 = 0 OR version = $2)", id, version)
+	if isForeignKeyViolation(err) {
+		return ErrTeacherHasCourses
+	}
+	return ds.checkDeleted(ctx, result, err, "teachers", id, ErrTeacherNotFound)
+}
+
+func (ds *sqlDataSource) DeleteStudent(ctx context.Context, id, version int) error {
+	result, err := ds.conn().ExecContext(ctx, "DELETE FROM students WHERE id = $1 AND ($2 = 0 OR version = $2)", id, version)
+	return ds.checkDeleted(ctx, result, err, "students", id, ErrStudentNotFound)
+}
+
+func (ds *sqlDataSource) DeleteCourse(ctx context.Context, id, version int) error {
+	result, err := ds.conn().ExecContext(ctx, "DELETE FROM courses WHERE id = $1 AND ($2 = 0 OR version = $2)", id, version)
+	return ds.checkDeleted(ctx, result, err, "courses", id, ErrCourseNotFound)
+}
+
+func (ds *sqlDataSource) ReassignCourses(ctx context.Context, fromTeacherID, toTeacherID int) (int, error) {
+	if toTeacherID != 0 {
+		if err := ds.checkExists(ctx, "teachers", toTeacherID, ErrUnknownTeacher); err != nil {
+			return 0, err
+		}
+	}
+	result, err := ds.conn().ExecContext(ctx, "UPDATE courses SET teacher_id = $2, version = version + 1 WHERE teacher_id = $1",
+		fromTeacherID, nullID(toTeacherID))
+	if isForeignKeyViolation(err) {
+		return 0, ErrUnknownTeacher
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// checkDeleted различает, почему DELETE с условием на версию не удалил строку:
+// строки нет (notFound) или у нее другая версия (ErrVersionMismatch)
+func (ds *sqlDataSource) checkDeleted(ctx context.Context, result sql.Result, err error, table string, id int, notFound error) error {
+	if err := checkAffected(result, err, notFound); !errors.Is(err, notFound) {
+		return err
+	}
+	return versionConflict(ctx, ds.conn(), table, id, notFound)
+}
+
+func (ds *sqlDataSource) EnrollStudent(ctx context.Context, studentID, courseID int) (Enrollment, error) {
+	// Ссылки проверяются до вставки: после ошибки внутри транзакции Postgres
+	// отвергает любые запросы до ROLLBACK, а SQLite не сообщает имя нарушенного ограничения
+	if err := ds.checkExists(ctx, "students", studentID, ErrStudentNotFound); err != nil {
+		return Enrollment{}, err
+	}
+	if err := ds.checkExists(ctx, "courses", courseID, ErrCourseNotFound); err != nil {
+		return Enrollment{}, err
+	}
+
+	enrollment := Enrollment{StudentID: studentID, CourseID: courseID}
+	err := ds.conn().QueryRowContext(ctx, "INSERT INTO enrollments (student_id, course_id) VALUES ($1, $2) RETURNING enrolled_at",
+		studentID, courseID).Scan(&enrollment.EnrolledAt)
+	switch {
+	case isUniqueViolation(err):
+		return Enrollment{}, ErrAlreadyEnrolled
+	case isForeignKeyViolation(err):
+		// Курс или студента удалили между проверкой и вставкой
+		return Enrollment{}, ds.missingEnrollmentRef(ctx, err, studentID)
+	case err != nil:
+		return Enrollment{}, err
+	}
+	return enrollment, nil
+}
+
+// missingEnrollmentRef определяет, чья строка пропала, когда вставка в enrollments нарушила
+// внешний ключ. Postgres называет ограничение, но запросов в прерванной транзакции больше
+// не выполняет; SQLite ограничение не называет, зато транзакцию не прерывает, и студента
+// можно проверить заново
+func (ds *sqlDataSource) missingEnrollmentRef(ctx context.Context, err error, studentID int) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if pqErr.Constraint == "enrollments_student_id_fkey" {
+			return ErrStudentNotFound
+		}
+		return ErrCourseNotFound
+	}
+	if err := ds.checkExists(ctx, "students", studentID, ErrStudentNotFound); err != nil {
+		return err
+	}
+	return ErrCourseNotFound
+}
+
+func (ds *sqlDataSource) UnenrollStudent(ctx context.Context, studentID, courseID int) error {
+	result, err := ds.conn().ExecContext(ctx, "DELETE FROM enrollments WHERE student_id = $1 AND course_id = $2", studentID, courseID)
+	return checkAffected(result, err, ErrEnrollmentNotFound)
+}
+
+func (ds *sqlDataSource) GetCourseStudents(ctx context.Context, courseID int) ([]Student, error) {
+	if err := ds.checkExists(ctx, "courses", courseID, ErrCourseNotFound); err != nil {
+		return nil, err
+	}
+
+	rows, err := ds.conn().QueryContext(ctx, `SELECT s.id, s.name, s.email FROM students s
+		JOIN enrollments e ON e.student_id = s.id
+		WHERE e.course_id = $1 ORDER BY s.id`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := []Student{}
+	for rows.Next() {
+		var student Student
+		if err := rows.Scan(&student.ID, &student.Name, &student.Email); err != nil {
+			return nil, err
+		}
+		students = append(students, student)
+	}
+
+	return students, rows.Err()
+}
+
+func (ds *sqlDataSource) GetStudentCourses(ctx context.Context, studentID int) ([]Course, error) {
+	if err := ds.checkExists(ctx, "students", studentID, ErrStudentNotFound); err != nil {
+		return nil, err
+	}
+
+	rows, err := ds.conn().QueryContext(ctx, `SELECT c.id, c.title, COALESCE(c.description, ''), c.teacher_id, c.price FROM courses c
+		JOIN enrollments e ON e.course_id = c.id
+		WHERE e.student_id = $1 ORDER BY c.id`, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	courses := []Course{}
+	for rows.Next() {
+		var course Course
+		var teacherID sql.NullInt64
+		if err := rows.Scan(&course.ID, &course.Title, &course.Description, &teacherID, &course.Price); err != nil {
+			return nil, err
+		}
+		course.TeacherID = int(teacherID.Int64)
+		courses = append(courses, course)
+	}
+
+	return courses, rows.Err()
+}
+
+// userColumns колонки users в порядке полей scanUser
+const userColumns = "id, username, COALESCE(password_hash, ''), role, teacher_id, student_id, failed_logins, locked_until, password_changed_at, created_at"
+
+func (ds *sqlDataSource) CreateUser(ctx context.Context, user User) (User, error) {
+	err := ds.conn().QueryRowContext(ctx, `INSERT INTO users (username, password_hash, role, teacher_id, student_id)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5) RETURNING id, created_at`,
+		user.Username, user.PasswordHash, user.Role, nullID(user.TeacherID), nullID(user.StudentID)).
+		Scan(&user.ID, &user.CreatedAt)
+	switch {
+	case isUniqueViolation(err):
+		return User{}, ErrUserExists
+	case isForeignKeyViolation(err) && user.TeacherID != 0:
+		return User{}, ErrTeacherNotFound
+	case isForeignKeyViolation(err):
+		return User{}, ErrStudentNotFound
+	}
+	return user, err
+}
+
+func (ds *sqlDataSource) GetUserByID(ctx context.Context, id int) (User, error) {
+	return scanUser(ds.conn().QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+}
+
+func (ds *sqlDataSource) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	return scanUser(ds.conn().QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE username = $1", username))
+}
+
+func (ds *sqlDataSource) SetPassword(ctx context.Context, userID int, passwordHash string) error {
+	result, err := ds.conn().ExecContext(ctx,
+		"UPDATE users SET password_hash = $2, password_changed_at = $3, failed_logins = 0, locked_until = NULL WHERE id = $1",
+		userID, passwordHash, time.Now())
+	return checkAffected(result, err, ErrUserNotFound)
+}
+
+func (ds *sqlDataSource) RecordLoginFailure(ctx context.Context, userID, maxFailures int, lockUntil time.Time) error {
+	// Счетчик увеличивается в самом запросе, чтобы одновременные попытки не терялись
+	result, err := ds.conn().ExecContext(ctx, `UPDATE users SET
+		failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+		locked_until = CASE WHEN failed_logins + 1 >= $2 THEN $3 ELSE locked_until END
+		WHERE id = $1`, userID, maxFailures, lockUntil)
+	return checkAffected(result, err, ErrUserNotFound)
+}
+
+func (ds *sqlDataSource) ResetLoginFailures(ctx context.Context, userID int) error {
+	result, err := ds.conn().ExecContext(ctx, "UPDATE users SET failed_logins = 0 WHERE id = $1", userID)
+	return checkAffected(result, err, ErrUserNotFound)
+}
+
+func (ds *sqlDataSource) RenameTeacherUser(ctx context.Context, teacherID int, username string) error {
+	return ds.renameUser(ctx, "teacher_id", teacherID, username)
+}
+
+func (ds *sqlDataSource) RenameStudentUser(ctx context.Context, studentID int, username string) error {
+	return ds.renameUser(ctx, "student_id", studentID, username)
+}
+
+func (ds *sqlDataSource) renameUser(ctx context.Context, column string, id int, username string) error {
+	_, err := ds.conn().ExecContext(ctx, "UPDATE users SET username = $2 WHERE "+column+" = $1", id, username)
+	if isUniqueViolation(err) {
+		return ErrUserExists
+	}
+	return err
+}
+
+func (ds *sqlDataSource) CreateUserToken(ctx context.Context, token UserToken) error {
+	_, err := ds.conn().ExecContext(ctx, "INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at) VALUES ($1, $2, $3, $4)",
+		token.Hash, token.UserID, token.Purpose, token.ExpiresAt)
+	if isForeignKeyViolation(err) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func (ds *sqlDataSource) ConsumeUserToken(ctx context.Context, hash, purpose string, now time.Time) (int, error) {
+	var userID int
+	err := ds.conn().QueryRowContext(ctx, `UPDATE user_tokens SET used_at = $3
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING user_id`, hash, purpose, now).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidUserToken
+	}
+	return userID, err
+}
+
+func scanUser(row *sql.Row) (User, error) {
+	var user User
+	var teacherID, studentID sql.NullInt64
+	var lockedUntil, passwordChangedAt sql.NullTime
+	err := row.Scan(&user.ID, &user.Username, &user.PasswordHash, &user.Role, &teacherID, &studentID,
+		&user.FailedLogins, &lockedUntil, &passwordChangedAt, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	user.TeacherID = int(teacherID.Int64)
+	user.StudentID = int(studentID.Int64)
+	user.LockedUntil = lockedUntil.Time
+	user.PasswordChangedAt = passwordChangedAt.Time
+	return user, err
+}
+
+// execer общие методы *sql.DB и *sql.Tx, чтобы запросы выполнялись как отдельно, так и в транзакции
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn возвращает транзакцию Transact, если она открыта, иначе пул соединений
+func (ds *sqlDataSource) conn() execer {
+	if ds.tx != nil {
+		return ds.tx
+	}
+	return ds.db
+}
+
+// maxTxAttempts сколько раз Transact выполняет транзакцию, прерванную конфликтом сериализации
+const maxTxAttempts = 5
+
+// Transact выполняет fn в одной транзакции с уровнем изоляции dialect.isolation. Запросы
+// хранилища, переданного fn, идут в эту транзакцию; ошибка fn откатывает ее целиком.
+// Транзакция, прерванная конфликтом сериализации или взаимной блокировкой, повторяется
+// с растущей случайной паузой, поэтому fn может выполниться несколько раз и не должна
+// иметь побочных эффектов вне хранилища. Вложенный вызов выполняется в уже открытой транзакции
+func (ds *sqlDataSource) Transact(ctx context.Context, fn func(tx Store) error) error {
+	if ds.tx != nil {
+		return fn(ds)
+	}
+	for attempt := 1; ; attempt++ {
+		err := ds.inTx(ctx, ds.dialect.isolation, func(tx *sql.Tx) error {
+			return fn(&sqlDataSource{db: ds.db, tx: tx, migrator: ds.migrator, dialect: ds.dialect})
+		})
+		if attempt == maxTxAttempts || !isSerializationFailure(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay(attempt)):
+		}
+	}
+}
+
+// retryDelay пауза перед повтором транзакции: случайная, чтобы конфликтующие транзакции
+// не столкнулись снова, и в среднем удваивается с каждой попыткой (10, 20, 40 мс...)
+func retryDelay(attempt int) time.Duration {
+	return rand.N(20 * time.Millisecond << (attempt - 1))
+}
+
+// inTx выполняет fn в транзакции; транзакция фиксируется, только если fn не вернула ошибку.
+// Внутри Transact fn выполняется в уже открытой транзакции
+func (ds *sqlDataSource) inTx(ctx context.Context, isolation sql.IsolationLevel, fn func(tx *sql.Tx) error) error {
+	if ds.tx != nil {
+		return fn(ds.tx)
+	}
+	tx, err := ds.db.BeginTx(ctx, &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// versionConflict вызывается, когда запрос с условием на версию не затронул строку:
+// возвращает ErrVersionMismatch, если строка есть, иначе notFound
+func versionConflict(ctx context.Context, q execer, table string, id int, notFound error) error {
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1)", id).Scan(&exists)
+	switch {
+	case err != nil:
+		return err
+	case exists:
+		return ErrVersionMismatch
+	}
+	return notFound
+}
+
+// checkExists возвращает notFound, если в таблице table нет строки с указанным id
+func (ds *sqlDataSource) checkExists(ctx context.Context, table string, id int, notFound error) error {
+	var exists bool
+	err := ds.conn().QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1)", id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return notFound
+	}
+	return nil
+}
+
+// listQuery строит запрос страницы списка и запрос общего числа записей по тем же фильтрам.
+// Имя колонки сортировки к этому моменту проверено ListParams.normalize по белому списку.
+// Запрашивается Limit+1 строка, чтобы newPage мог понять, есть ли следующая страница
+func (d dialect) listQuery(columns, table, nameColumn string, params ListParams) (query, countQuery string, args, countArgs []interface{}) {
+	var where []string
+	// Регистр приводится и у колонки, и у префикса: LIKE в SQLite без этого
+	// не учитывает регистр только для латиницы
+	if params.Name != "" {
+		args = append(args, likePrefix(foldCase(params.Name)))
+		where = append(where, fmt.Sprintf(`%s(%s) LIKE $%d ESCAPE '\'`, d.fold, nameColumn, len(args)))
+	}
+	if params.Email != "" {
+		args = append(args, likePrefix(foldCase(params.Email)))
+		where = append(where, fmt.Sprintf(`%s(email) LIKE $%d ESCAPE '\'`, d.fold, len(args)))
+	}
+
+	countQuery = "SELECT COUNT(*) FROM " + table
+	if len(where) > 0 {
+		countQuery += " WHERE " + strings.Join(where, " AND ")
+	}
+	countArgs = append([]interface{}(nil), args...)
+
+	sortColumn := d.sortExpr(params.Sort)
+	direction, compare := "ASC", ">"
+	if params.Desc {
+		direction, compare = "DESC", "<"
+	}
+	if params.after != nil {
+		args = append(args, params.after.Value, params.after.ID)
+		where = append(where, fmt.Sprintf("(%s, id) %s ($%d, $%d)", sortColumn, compare, len(args)-1, len(args)))
+	}
+
+	query = "SELECT " + columns + " FROM " + table
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, params.Limit+1, params.Offset)
+	query += fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT $%d OFFSET $%d",
+		sortColumn, direction, direction, len(args)-1, len(args))
+
+	return query, countQuery, args, countArgs
+}
+
+// sortExpr выражение сортировки, совпадающее с порядком хранилища в памяти:
+// строки сравниваются побайтно, отсутствующий teacher_id считается нулем
+func (d dialect) sortExpr(column string) string {
+	switch {
+	case textSortColumns[column]:
+		return column + " " + d.collate
+	case column == "teacher_id":
+		return "COALESCE(teacher_id, 0)"
+	}
+	return column
+}
+
+// likePrefix превращает строку в шаблон LIKE «начинается с», экранируя спецсимволы
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
+// checkAffected возвращает ошибку notFound, если запрос не затронул ни одной строки
+func checkAffected(result sql.Result, err error, notFound error) error {
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// isForeignKeyViolation сообщает, нарушил ли запрос ограничение внешнего ключа
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	var liteErr *sqlite.Error
+	switch {
+	case errors.As(err, &pqErr):
+		return pqErr.Code == "23503"
+	case errors.As(err, &liteErr):
+		return liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	}
+	return false
+}
+
+// isUniqueViolation сообщает, нарушил ли запрос ограничение уникальности
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	var liteErr *sqlite.Error
+	switch {
+	case errors.As(err, &pqErr):
+		return pqErr.Code == "23505"
+	case errors.As(err, &liteErr):
+		return liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
+
+// nullID превращает нулевой идентификатор в NULL для необязательных внешних ключей
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
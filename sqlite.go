@@ -0,0 +1,105 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteDialect синтаксис SQLite. Встроенные lower() и LIKE меняют регистр только у ASCII,
+// поэтому фильтры используют функцию fold_case. Строки не блокируются: транзакции
+// начинаются с BEGIN IMMEDIATE и сразу получают блокировку записи на всю базу
+var sqliteDialect = dialect{
+	driver:     "sqlite",
+	fold:       "fold_case",
+	collate:    "COLLATE BINARY",
+	timestamp:  "TIMESTAMP",
+	migrations: "migrations/sqlite",
+}
+
+func init() {
+	// fold_case доступна во всех соединениях, открытых после регистрации
+	sqlite.MustRegisterDeterministicScalarFunction("fold_case", 1,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			switch v := args[0].(type) {
+			case string:
+				return foldCase(v), nil
+			case []byte:
+				return foldCase(string(v)), nil
+			}
+			return args[0], nil
+		})
+}
+
+// SQLiteDataSource хранилище в файле SQLite; драйвер написан на Go и не требует cgo
+type SQLiteDataSource struct {
+	*sqlDataSource
+}
+
+// NewSQLiteDataSource открывает (или создает) файл базы из DSN sqlite://<путь>
+// и применяет непримененные миграции схемы
+func NewSQLiteDataSource(cfg DBConfig) (*SQLiteDataSource, error) {
+	db, err := openSQLite(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ds, err := newSQLDataSource(db, sqliteDialect)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteDataSource{ds}, nil
+}
+
+// openSQLite открывает базу с включенными внешними ключами. SQLite допускает
+// одного пишущего, поэтому пул ограничен одним соединением: запросы выполняются
+// по очереди, а не получают SQLITE_BUSY
+func openSQLite(cfg DBConfig) (*sql.DB, error) {
+	dsn, err := sqliteDSN(cfg.URL())
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(sqliteDialect.driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+	return db, nil
+}
+
+// sqliteDSN переводит sqlite://<путь>[?параметры] в строку подключения драйвера.
+// Путь может быть относительным (sqlite://school.db) или абсолютным (sqlite:///var/lib/school.db)
+func sqliteDSN(dsn string) (string, error) {
+	path, query, _ := strings.Cut(strings.TrimPrefix(dsn, "sqlite://"), "?")
+	if path == "" {
+		return "", errors.New("sqlite dsn must name a database file, e.g. sqlite://school.db")
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", fmt.Errorf("invalid dsn: %w", err)
+	}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Set("_txlock", "immediate")
+	return "file:" + path + "?" + params.Encode(), nil
+}
+
+// isSQLiteBusy сообщает, что база заблокирована другим процессом дольше busy_timeout
+func isSQLiteBusy(err error) bool {
+	var liteErr *sqlite.Error
+	if !errors.As(err, &liteErr) {
+		return false
+	}
+	switch liteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+		return true
+	}
+	return false
+}
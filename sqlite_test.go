@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	tests := []struct {
+		dsn     string
+		want    string
+		wantErr bool
+	}{
+		{"sqlite://school.db", "file:school.db?_pragma=foreign_keys%281%29&_pragma=busy_timeout%285000%29&_txlock=immediate", false},
+		{"sqlite:///var/lib/school.db", "file:/var/lib/school.db?_pragma=foreign_keys%281%29&_pragma=busy_timeout%285000%29&_txlock=immediate", false},
+		{"sqlite://school.db?_pragma=journal_mode(WAL)",
+			"file:school.db?_pragma=journal_mode%28WAL%29&_pragma=foreign_keys%281%29&_pragma=busy_timeout%285000%29&_txlock=immediate", false},
+		{"sqlite://", "", true},
+		{"sqlite://school.db?%zz", "", true},
+	}
+	for _, tt := range tests {
+		got, err := sqliteDSN(tt.dsn)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("sqliteDSN(%q) = %q, %v; want %q", tt.dsn, got, err, tt.want)
+		}
+	}
+}
+
+func TestNewDataSourceSchemes(t *testing.T) {
+	tests := []struct {
+		dsn     string
+		want    string
+		wantErr string
+	}{
+		{"memory://", "*main.MemoryDataSource", ""},
+		{"sqlite://" + filepath.Join(t.TempDir(), "school.db"), "*main.SQLiteDataSource", ""},
+		{"mysql://localhost/school", "", `unsupported dsn scheme "mysql"`},
+	}
+	for _, tt := range tests {
+		ds, err := NewDataSource(DBConfig{DSN: tt.dsn})
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("NewDataSource(%q): err = %v, want %q", tt.dsn, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("NewDataSource(%q): %v", tt.dsn, err)
+		}
+		if got := fmt.Sprintf("%T", ds); got != tt.want {
+			t.Errorf("NewDataSource(%q) = %s, want %s", tt.dsn, got, tt.want)
+		}
+		ds.Close()
+	}
+}
+
+func TestSQLiteDataSurvivesReopen(t *testing.T) {
+	cfg := DBConfig{DSN: "sqlite://" + filepath.Join(t.TempDir(), "school.db")}
+	ctx := context.Background()
+
+	ds, err := NewSQLiteDataSource(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestService(ds)
+	teacher := mustCreateTeacher(t, s, "Иван Петрович", "ivan@example.com")
+	course, err := s.CreateCourse(ctx, Course{Title: "Go", TeacherID: teacher.ID, Price: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Повторное открытие не применяет миграции заново и видит сохраненные записи
+	ds, err = NewSQLiteDataSource(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	if pending, err := ds.PendingMigrations(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("pending migrations after reopen = %v, %v", pending, err)
+	}
+	got, err := ds.GetCourseByID(ctx, course.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Go" || got.TeacherID != teacher.ID {
+		t.Fatalf("course after reopen = %+v", got)
+	}
+}
+
+func TestSQLiteEnforcesForeignKeys(t *testing.T) {
+	ds, err := NewSQLiteDataSource(DBConfig{DSN: "sqlite://" + filepath.Join(t.TempDir(), "school.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	s := newTestService(ds)
+	teacher := mustCreateTeacher(t, s, "Иван Петрович", "ivan@example.com")
+	if _, err := s.CreateCourse(context.Background(), Course{Title: "Go", TeacherID: teacher.ID, Price: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Запрос в обход сервиса: ссылку на преподавателя проверяет сама база
+	_, err = ds.db.Exec("DELETE FROM teachers WHERE id = $1", teacher.ID)
+	if !isForeignKeyViolation(err) {
+		t.Fatalf("deleting a referenced teacher: err = %v, want a foreign key violation", err)
+	}
+}
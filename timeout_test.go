@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stalledDataSource хранилище, запрос списка к которому завершается только по ctx
+type stalledDataSource struct {
+	DataSource
+}
+
+func (stalledDataSource) GetAllTeachers(ctx context.Context, params ListParams) (Page[Teacher], error) {
+	<-ctx.Done()
+	return Page[Teacher]{}, ctx.Err()
+}
+
+func TestInterruptedStorageOperation(t *testing.T) {
+	tests := []struct {
+		name       string
+		cancel     bool
+		wantKind   ErrorKind
+		wantReason string
+	}{
+		{"timeout", false, KindTimeout, "timeout"},
+		{"client gone", true, KindCanceled, "canceled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := NewMemoryDataSource()
+			timeouts := DefaultConfig().DB.Timeouts
+			timeouts.List.Duration = 10 * time.Millisecond
+			metrics := NewMetrics(ds)
+			s := NewService(stalledDataSource{ds}, timeouts, metrics, testLogger)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			_, err := s.GetAllTeachers(ctx, ListParams{})
+			var domainErr *Error
+			if !errors.As(err, &domainErr) || domainErr.Kind != tt.wantKind {
+				t.Fatalf("err = %v, want kind %s", err, tt.wantKind)
+			}
+			if got := metrics.interrupted[interruptKey{operation: "list_teachers", reason: tt.wantReason}]; got != 1 {
+				t.Fatalf("interrupted %s = %d, want 1", tt.wantReason, got)
+			}
+		})
+	}
+}
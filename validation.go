@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+// Ограничения полей; длины строк совпадают с размерами столбцов в миграциях
+const (
+	maxNameLength        = 255
+	maxEmailLength       = 254
+	maxTitleLength       = 255
+	maxDescriptionLength = 10000
+	// maxPrice граница NUMERIC(10, 2)
+	maxPrice = 1e8
+)
+
+// FieldError ошибка одного поля тела запроса
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// validator накапливает ошибки полей, чтобы клиент получил их все в одном ответе
+type validator struct {
+	fields []FieldError
+}
+
+// check добавляет ошибку поля, если ok ложно. Для поля сохраняется только первая ошибка
+func (v *validator) check(ok bool, field, code, message string) {
+	if ok || v.has(field) {
+		return
+	}
+	v.fields = append(v.fields, FieldError{Field: field, Code: code, Message: message})
+}
+
+func (v *validator) has(field string) bool {
+	for _, f := range v.fields {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) required(value, field string) {
+	v.check(value != "", field, "required", field+" is required")
+}
+
+func (v *validator) maxLength(value, field string, max int) {
+	v.check(len([]rune(value)) <= max, field, "too_long", fmt.Sprintf("%s must be at most %d characters", field, max))
+}
+
+// email проверяет, что value — одиночный адрес без отображаемого имени
+func (v *validator) email(value, field string) {
+	v.required(value, field)
+	v.maxLength(value, field, maxEmailLength)
+	addr, err := mail.ParseAddress(value)
+	v.check(err == nil && addr.Address == value, field, "invalid_email", field+" must be a valid email address")
+}
+
+// err возвращает ErrInvalidFields со всеми накопленными ошибками или nil
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return invalidFields(v.fields...)
+}
+
+// validatePerson проверяет общие поля преподавателя и студента
+func validatePerson(name, email string) error {
+	return checkPerson(name, email).err()
+}
+
+func checkPerson(name, email string) *validator {
+	var v validator
+	v.required(name, "name")
+	v.maxLength(name, "name", maxNameLength)
+	v.email(email, "email")
+	return &v
+}
+
+// checker тело запроса, поля которого проверяются без обращения к хранилищу.
+// decodeStrict добавляет эти ошибки к ошибкам неизвестных полей, чтобы клиент получил их все сразу
+type checker interface {
+	check() *validator
+}
+
+func (t Teacher) check() *validator {
+	t = t.normalize()
+	return checkPerson(t.Name, t.Email)
+}
+
+func (s Student) check() *validator {
+	s = s.normalize()
+	return checkPerson(s.Name, s.Email)
+}
+
+func (c Course) check() *validator {
+	return checkCourse(c.normalize())
+}
+
+// normalize убирает пробелы по краям строковых полей
+func (t Teacher) normalize() Teacher {
+	t.Name = strings.TrimSpace(t.Name)
+	t.Email = strings.TrimSpace(t.Email)
+	return t
+}
+
+func (s Student) normalize() Student {
+	s.Name = strings.TrimSpace(s.Name)
+	s.Email = strings.TrimSpace(s.Email)
+	return s
+}
+
+func (c Course) normalize() Course {
+	c.Title = strings.TrimSpace(c.Title)
+	c.Description = strings.TrimSpace(c.Description)
+	return c
+}
+
+// checkCourse проверяет поля курса, не обращаясь к хранилищу; существование
+// преподавателя проверяет Service.validateCourse
+func checkCourse(course Course) *validator {
+	var v validator
+	v.required(course.Title, "title")
+	v.maxLength(course.Title, "title", maxTitleLength)
+	v.maxLength(course.Description, "description", maxDescriptionLength)
+	v.check(course.TeacherID > 0, "teacher_id", "required", "teacher_id is required")
+	v.check(course.Price >= 0, "price", "negative", "price must not be negative")
+	v.check(course.Price < maxPrice, "price", "too_large", fmt.Sprintf("price must be less than %.0f", maxPrice))
+	return &v
+}
+
+// decodeJSON строго разбирает тело запроса в v: неизвестные поля, данные после объекта
+// и превышение лимита размера (см. limitBody) считаются ошибкой
+func decodeJSON(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	return decodeStrict(r.Body, v)
+}
+
+// decodeStrict разбирает в v ровно один JSON-объект без неизвестных полей.
+// Неизвестные поля и значения неверного типа не прерывают разбор: остальные поля
+// разбираются и проверяются (см. checker), и все ошибки возвращаются одним списком
+func decodeStrict(body io.Reader, v interface{}) error {
+	dec := json.NewDecoder(body)
+	var data json.RawMessage
+	if err := dec.Decode(&data); err != nil {
+		return bodyError(err)
+	}
+	if dec.Decode(&json.RawMessage{}) != io.EOF {
+		return invalidJSON("unexpected data after JSON object")
+	}
+
+	var errs validator
+	for {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(v)
+		if err == nil {
+			break
+		}
+		field, ok := fieldError(err)
+		if !ok {
+			return bodyError(err)
+		}
+		errs.fields = append(errs.fields, field)
+		// Поле убирается из объекта, и разбор повторяется, чтобы найти остальные ошибки
+		if data, ok = withoutField(data, field.Field); !ok {
+			return errs.err()
+		}
+	}
+	if c, ok := v.(checker); ok && len(errs.fields) > 0 {
+		for _, f := range c.check().fields {
+			errs.check(false, f.Field, f.Code, f.Message)
+		}
+	}
+	return errs.err()
+}
+
+// withoutField возвращает JSON-объект data без ключа name; false, если такого ключа нет
+func withoutField(data []byte, name string) ([]byte, bool) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, false
+	}
+	if _, ok := object[name]; !ok {
+		return nil, false
+	}
+	delete(object, name)
+	data, err := json.Marshal(object)
+	return data, err == nil
+}
+
+// fieldError приводит ошибку разбора, относящуюся к одному полю, к FieldError
+func fieldError(err error) (FieldError, bool) {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return FieldError{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: fmt.Sprintf("%s must be %s", typeErr.Field, jsonType(typeErr.Type.Kind().String())),
+		}, true
+	}
+	// Текст ошибки DisallowUnknownFields: json: unknown field "name"
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if field, uerr := strconv.Unquote(name); uerr == nil {
+			name = field
+		}
+		return FieldError{Field: name, Code: "unknown_field", Message: "unknown field " + name}, true
+	}
+	return FieldError{}, false
+}
+
+// bodyError приводит ошибку разбора тела запроса к *Error
+func bodyError(err error) error {
+	var (
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+		tooLargeErr *http.MaxBytesError
+	)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, io.EOF):
+		return ErrEmptyBody
+	case errors.As(err, &tooLargeErr):
+		return &Error{
+			Kind:    KindTooLarge,
+			Code:    ErrBodyTooLarge.Code,
+			Message: fmt.Sprintf("request body must not exceed %d bytes", tooLargeErr.Limit),
+		}
+	case errors.As(err, &syntaxErr):
+		return invalidJSON(fmt.Sprintf("syntax error at byte %d", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return invalidJSON("unexpected end of JSON")
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		return invalidJSON("request body must be a JSON object")
+	}
+	if field, ok := fieldError(err); ok {
+		return invalidFields(field)
+	}
+	return invalidJSON(err.Error())
+}
+
+// jsonType название типа JSON для сообщения об ошибке
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"):
+		return "an integer"
+	case strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case kind == "slice", kind == "array":
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// limitBody ограничивает размер тела запроса; decodeJSON отвечает на превышение 413
+func limitBody(maxBytes int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
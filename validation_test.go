@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fieldCodes возвращает пары поле:код из ошибки 422
+func fieldCodes(t *testing.T, err error) []string {
+	t.Helper()
+	var domainErr *Error
+	if !errors.As(err, &domainErr) || domainErr.Kind != KindUnprocessable {
+		t.Fatalf("err = %v, want a field error", err)
+	}
+	codes := make([]string, len(domainErr.Fields))
+	for i, f := range domainErr.Fields {
+		codes[i] = f.Field + ":" + f.Code
+	}
+	return codes
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantCodes []string
+		wantErr   error
+	}{
+		{"valid", `{"name":"Анна","email":"anna@example.com"}`, nil, nil},
+		{"unknown field with invalid fields", `{"name":"","email":"bad","extra":1}`,
+			[]string{"extra:unknown_field", "name:required", "email:invalid_email"}, nil},
+		{"several unknown fields", `{"name":"Анна","email":"anna@example.com","a":1,"b":2}`,
+			[]string{"a:unknown_field", "b:unknown_field"}, nil},
+		{"wrong type and unknown field", `{"name":5,"email":"anna@example.com","extra":true}`,
+			[]string{"name:invalid_type", "extra:unknown_field"}, nil},
+		{"wrong type alone", `{"name":5,"email":"anna@example.com"}`, []string{"name:invalid_type"}, nil},
+		{"version is not writable", `{"name":"Анна","email":"anna@example.com","version":3}`,
+			[]string{"version:unknown_field"}, nil},
+		{"trailing data", `{"name":"Анна"} {}`, nil, ErrInvalidJSON},
+		{"not an object", `["Анна"]`, nil, ErrInvalidJSON},
+		{"empty", ``, nil, ErrEmptyBody},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var student Student
+			err := decodeStrict(strings.NewReader(tt.body), &student)
+			switch {
+			case tt.wantErr != nil:
+				var domainErr *Error
+				if !errors.As(err, &domainErr) || domainErr.Code != tt.wantErr.(*Error).Code {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantCodes == nil:
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+			default:
+				// После удаления поля ключи объекта сортируются, поэтому порядок ошибок не проверяется
+				got := fieldCodes(t, err)
+				if !sameElements(got, tt.wantCodes) {
+					t.Fatalf("fields = %v, want %v", got, tt.wantCodes)
+				}
+			}
+		})
+	}
+}
+
+func sameElements(got, want []string) bool {
+	count := make(map[string]int)
+	for _, s := range got {
+		count[s]++
+	}
+	for _, s := range want {
+		count[s]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return len(got) == len(want)
+}
+
+func TestInvalidBodyResponse(t *testing.T) {
+	api := newTestAPI(t, NewMemoryDataSource())
+	w := api.do(http.MethodPost, "/teachers", api.adminToken, `{"name":"","email":"bad","extra":1}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", w.Code, w.Body)
+	}
+	var body errorResponse
+	decodeBody(t, w, &body)
+	var got []string
+	for _, f := range body.Fields {
+		got = append(got, f.Field)
+	}
+	if want := []string{"extra", "name", "email"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+}
+
+func TestEmailIsStoredAndUnique(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, ds DataSource) {
+		api := newTestAPI(t, ds)
+		for _, kind := range []string{"teachers", "students"} {
+			t.Run(kind, func(t *testing.T) {
+				w := api.do(http.MethodPost, "/"+kind, api.adminToken, `{"name":"Анна","email":"anna@example.com"}`)
+				if w.Code != http.StatusCreated {
+					t.Fatalf("create: status = %d: %s", w.Code, w.Body)
+				}
+				if w := api.do(http.MethodPost, "/"+kind, api.adminToken, `{"name":"Борис","email":"boris@example.com"}`); w.Code != http.StatusCreated {
+					t.Fatalf("create: status = %d: %s", w.Code, w.Body)
+				}
+
+				// Адрес возвращается и в списке, и в отдельной записи
+				w = api.do(http.MethodGet, "/"+kind+"?name=Борис", api.adminToken, "")
+				var page Page[Student]
+				decodeBody(t, w, &page)
+				if len(page.Items) != 1 || page.Items[0].Email != "boris@example.com" {
+					t.Fatalf("list = %+v", page)
+				}
+				second := page.Items[0]
+				w = api.do(http.MethodGet, "/"+kind+"/"+strconv.Itoa(second.ID), api.adminToken, "")
+				var got Student
+				decodeBody(t, w, &got)
+				if got.Email != "boris@example.com" {
+					t.Fatalf("get = %+v", got)
+				}
+
+				tests := []struct {
+					name       string
+					method     string
+					target     string
+					body       string
+					wantStatus int
+					wantCode   string
+				}{
+					{"invalid format", http.MethodPost, "/" + kind, `{"name":"Вера","email":"vera.example.com"}`,
+						http.StatusUnprocessableEntity, "invalid_fields"},
+					{"duplicate on create", http.MethodPost, "/" + kind, `{"name":"Анна 2","email":"anna@example.com"}`,
+						http.StatusConflict, "email_taken"},
+					{"duplicate on update", http.MethodPut, "/" + kind + "/" + strconv.Itoa(second.ID),
+						`{"name":"Борис","email":"anna@example.com"}`, http.StatusConflict, "email_taken"},
+					{"duplicate on patch", http.MethodPatch, "/" + kind + "/" + strconv.Itoa(second.ID),
+						`{"email":"anna@example.com"}`, http.StatusConflict, "email_taken"},
+				}
+				for _, tt := range tests {
+					w := api.do(tt.method, tt.target, api.adminToken, tt.body, "If-Match", "*")
+					var body errorResponse
+					decodeBody(t, w, &body)
+					if w.Code != tt.wantStatus || body.Code != tt.wantCode {
+						t.Errorf("%s: status = %d, body = %+v", tt.name, w.Code, body)
+					}
+					if len(body.Fields) == 0 && body.Field != "email" || len(body.Fields) > 0 && body.Fields[0].Field != "email" {
+						t.Errorf("%s: error does not point at email: %+v", tt.name, body)
+					}
+				}
+			})
+		}
+	})
+}